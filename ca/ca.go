@@ -2,74 +2,315 @@ package ca
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"math/big"
+	"net/url"
 	"time"
 
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/spiffe"
+	"github.com/sundae-party/pki/subject"
 	"github.com/sundae-party/pki/types"
 )
 
-// CreateCa generate new self signed CA
-func CreateCa(subject pkix.Name, start time.Time, duration time.Duration) *types.Cert {
+// Options options used to create a new CA
+type Options struct {
+	Subject  pkix.Name
+	Start    time.Time
+	Duration time.Duration
+	// Key algorithm and size of the CA private key, RSA 4096 if empty
+	Key types.KeyOptions
+	// SerialNumber of the CA certificate, a random serial is generated if nil
+	SerialNumber *big.Int
+	// MaxPathLen and MaxPathLenZero limit the number of intermediate CAs allowed below this CA,
+	// with the same meaning as in x509.Certificate (no limit if MaxPathLen is 0 and MaxPathLenZero is false, or -1)
+	MaxPathLen     int
+	MaxPathLenZero bool
+	// KeyUsage of the CA, keyCertSign and cRLSign if zero. keyCertSign is required.
+	KeyUsage x509.KeyUsage
+	// ExtKeyUsage and UnknownExtKeyUsage extended key usages of the CA, none by default
+	// so the CA does not restrict the usages of the certificates it issues
+	ExtKeyUsage        []x509.ExtKeyUsage
+	UnknownExtKeyUsage []asn1.ObjectIdentifier
+	// NameConstraints limit the names of the certificates the CA can issue
+	NameConstraints NameConstraints
+	// SPIFFETrustDomain if set, the CA is a SPIFFE signing CA of the trust domain: it gets the trust domain
+	// SPIFFE ID and its name constraints only permit URI SANs of the trust domain
+	SPIFFETrustDomain string
+	// SKIMethod method used to compute the subject key identifier, keys.DefaultSKIMethod if empty
+	SKIMethod keys.SKIMethod
+}
 
-	// Gen CA private key
-	caPrivKey, err := rsa.GenerateKey(rand.Reader, 4096)
+// CreateCa generate new self signed CA with a RSA 4096 private key, it panics on error.
+//
+// Deprecated: use CreateCaWithOptions, which returns the error.
+func CreateCa(subject pkix.Name, start time.Time, duration time.Duration) *types.Cert {
+	caObj, err := CreateCaWithOptions(Options{
+		Subject:  subject,
+		Start:    start,
+		Duration: duration,
+		Key:      types.DefaultKeyOptions,
+	})
 	if err != nil {
 		panic(err)
 	}
+	return caObj
+}
 
-	// Get CA pivate key in pem format
-	caPrivKeyPEM := new(bytes.Buffer)
-	pem.Encode(caPrivKeyPEM, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(caPrivKey),
-	})
+// CreateCaWithOptions generate new self signed CA
+func CreateCaWithOptions(opts Options) (*types.Cert, error) {
+
+	// Gen CA private key
+	caPrivKey, err := keys.Generate(opts.Key)
+	if err != nil {
+		return nil, err
+	}
 
 	// Gen CA certificate template
+	ca, err := caTemplate(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create self signed CA certificate from template signed by the CA private key
+	return sign(ca, caPrivKey.Public(), caPrivKey, &types.Cert{Cert: ca, Key: caPrivKey}, opts.SKIMethod)
+}
+
+// CreateIntermediate generate new intermediate CA signed by the parent CA.
+// The chain of the new CA is the parent cert followed by the parent chain.
+func CreateIntermediate(parent *types.Cert, opts Options) (*types.Cert, error) {
+
+	if err := CheckIssuer(parent, opts.Start); err != nil {
+		return nil, err
+	}
+
+	// Check the parent path length constraint allows a new intermediate CA
+	parentMaxPathLen := parent.Cert.MaxPathLen
+	if parentMaxPathLen == 0 && !parent.Cert.MaxPathLenZero {
+		parentMaxPathLen = -1
+	}
+	if parentMaxPathLen == 0 {
+		return nil, errors.New("parent CA path length constraint does not allow intermediate CAs")
+	}
+	if parentMaxPathLen > 0 {
+		unlimited := opts.MaxPathLen < 0 || (opts.MaxPathLen == 0 && !opts.MaxPathLenZero)
+		if unlimited {
+			opts.MaxPathLen = parentMaxPathLen - 1
+			opts.MaxPathLenZero = opts.MaxPathLen == 0
+		} else if opts.MaxPathLen >= parentMaxPathLen {
+			return nil, fmt.Errorf("max path length %d must be lower than the parent CA max path length %d", opts.MaxPathLen, parentMaxPathLen)
+		}
+	}
+
+	// Gen intermediate CA private key
+	caPrivKey, err := keys.Generate(opts.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Gen intermediate CA certificate template
+	ca, err := caTemplate(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return sign(ca, caPrivKey.Public(), caPrivKey, parent, opts.SKIMethod)
+}
+
+// caTemplate build a CA certificate template from the CA options
+func caTemplate(opts Options) (*x509.Certificate, error) {
+
+	// Gen CA random serial number
+	sn := opts.SerialNumber
+	if sn == nil {
+		var err error
+		sn, err = serial.New()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keyUsage := opts.KeyUsage
+	if keyUsage == 0 {
+		keyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+	if keyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, errors.New("the keyCertSign key usage is required for a CA")
+	}
+
 	ca := &x509.Certificate{
-		SerialNumber:          big.NewInt(2021),
-		Subject:               subject,
-		NotBefore:             start,
-		NotAfter:              start.Add(duration),
+		SerialNumber:          sn,
+		Subject:               opts.Subject,
+		NotBefore:             opts.Start,
+		NotAfter:              opts.Start.Add(opts.Duration),
 		IsCA:                  true,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           opts.ExtKeyUsage,
+		UnknownExtKeyUsage:    opts.UnknownExtKeyUsage,
+		KeyUsage:              keyUsage,
 		BasicConstraintsValid: true,
+		MaxPathLen:            opts.MaxPathLen,
+		MaxPathLenZero:        opts.MaxPathLenZero,
 	}
 
-	// Create self signed CA certificate from template signed by the CA private key
-	caBytes, err := x509.CreateCertificate(rand.Reader, ca, ca, &caPrivKey.PublicKey, caPrivKey)
+	// Pin the SPIFFE trust domain
+	constraints := opts.NameConstraints
+	if opts.SPIFFETrustDomain != "" {
+		if err := spiffe.ValidateTrustDomain(opts.SPIFFETrustDomain); err != nil {
+			return nil, err
+		}
+		ca.URIs = []*url.URL{spiffe.ID{TrustDomain: opts.SPIFFETrustDomain}.URL()}
+		constraints.PermittedURIDomains = append([]string{opts.SPIFFETrustDomain}, constraints.PermittedURIDomains...)
+		constraints.Critical = true
+	}
+	constraints.Apply(ca)
+	return ca, nil
+}
+
+// Sign sign CSR with given CA, it panics on error.
+// The CA and the CSR keys can be of different algorithms.
+//
+// Deprecated: use SignCert, which returns the error.
+func Sign(ca *types.Cert, csr *types.Cert) *types.Cert {
+	certObj, err := SignCert(ca, csr)
 	if err != nil {
 		panic(err)
 	}
+	return certObj
+}
 
-	// Get CA certificate in pem format
-	caPEM := new(bytes.Buffer)
-	pem.Encode(caPEM, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: caBytes,
-	})
+// SignCert sign CSR with given CA, the CSR names must be allowed by the CA name constraints.
+// The CA is checked with CheckIssuer, the returned errors wrap ErrNotCA, ErrCAExpired, ErrKeyMismatch or ErrNameConstraint.
+// The CA and the CSR keys can be of different algorithms.
+// The subject key identifier of the CSR is kept, or computed with the default method if unset.
+func SignCert(ca *types.Cert, csr *types.Cert) (*types.Cert, error) {
+	if csr == nil || csr.Cert == nil {
+		return nil, fmt.Errorf("%w: the certificate template is required", ErrNoCertificate)
+	}
+	if csr.Key == nil {
+		return nil, fmt.Errorf("%w: the private key of the certificate is required", keys.ErrNoPrivateKey)
+	}
+	return sign(csr.Cert, csr.Key.Public(), csr.Key, ca, keys.DefaultSKIMethod)
+}
+
+// SignOptions options used to sign a PKCS#10 certificate request
+type SignOptions struct {
+	// Profile key usages set on the certificate
+	Profile profile.Profile
+	// Start of the certificate validity, time.Now if zero
+	Start time.Time
+	// Duration of the certificate, the profile duration if zero. ErrNoValidity is returned if both are zero.
+	Duration time.Duration
+	// SerialNumber of the certificate, a random serial is generated if nil
+	SerialNumber *big.Int
+	// SKIMethod method used to compute the subject key identifier, keys.DefaultSKIMethod if empty
+	SKIMethod keys.SKIMethod
+}
 
-	caObj := &types.Cert{
-		CertPem: caPEM,
-		KeyPem:  caPrivKeyPEM,
-		Cert:    ca,
-		Key:     caPrivKey,
+// SignCSR sign a PKCS#10 certificate request with given CA.
+// The request signature is verified, the subject and SANs of the request are kept and
+// the usages of the profile are applied. The returned cert has no private key.
+func SignCSR(ca *types.Cert, req *x509.CertificateRequest, opts SignOptions) (*types.Cert, error) {
+	if req == nil {
+		return nil, fmt.Errorf("%w: the certificate request is required", ErrNoCertificate)
 	}
 
-	return caObj
+	// Check the requester owns the private key
+	if err := req.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid certificate request signature: %v", err)
+	}
+
+	// Reject malformed URI and email SANs
+	if err := csr.ValidateSans(req.URIs, req.EmailAddresses); err != nil {
+		return nil, err
+	}
+
+	sn := opts.SerialNumber
+	if sn == nil {
+		var err error
+		sn, err = serial.New()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Get the validity period
+	start := opts.Start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	duration := opts.Duration
+	if duration == 0 {
+		duration = opts.Profile.Duration
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("%w: a positive duration is required, got %s", ErrNoValidity, duration)
+	}
+
+	// Build the certificate template from the request
+	template := &x509.Certificate{
+		SerialNumber:   sn,
+		Subject:        req.Subject,
+		DNSNames:       req.DNSNames,
+		IPAddresses:    req.IPAddresses,
+		EmailAddresses: req.EmailAddresses,
+		URIs:           req.URIs,
+		NotBefore:      start,
+		NotAfter:       start.Add(duration),
+	}
+
+	// Keep the request attributes without pkix.Name field, like DC or custom OIDs
+	template.Subject.ExtraNames = subject.ExtraAttributes(req.Subject)
+	opts.Profile.Apply(template)
+	if err := opts.Profile.Check(template); err != nil {
+		return nil, err
+	}
+
+	return sign(template, req.PublicKey, nil, ca, opts.SKIMethod)
 }
 
-// Sign sign CSR with given CA
-func Sign(ca *types.Cert, csr *types.Cert) *types.Cert {
+// sign create the certificate from template for the public key and sign it with the issuer.
+// The private key is optional, it's only used to fill the key of the returned cert.
+// The names of the template are checked against the name constraints of the issuer and its chain.
+// The subject key identifier is computed from the public key with the method unless the template has one,
+// and the authority key identifier is always set from the issuer.
+func sign(template *x509.Certificate, pub crypto.PublicKey, key crypto.Signer, issuer *types.Cert, skiMethod keys.SKIMethod) (*types.Cert, error) {
+	if issuer == nil || issuer.Cert == nil {
+		return nil, fmt.Errorf("%w: the CA certificate is required", ErrNoCertificate)
+	}
+	if issuer.Key == nil {
+		return nil, fmt.Errorf("%w: the private key of CA %s is required", keys.ErrNoPrivateKey, issuer.Cert.Subject)
+	}
 
-	certBytes, err := x509.CreateCertificate(rand.Reader, csr.Cert, ca.Cert, &csr.Key.PublicKey, ca.Key)
+	if issuer.Cert != template {
+		if err := CheckIssuer(issuer, template.NotBefore); err != nil {
+			return nil, err
+		}
+		if err := CheckNameConstraints(template, issuer.Cert, issuer.Chain); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := setKeyIDs(template, pub, issuer.Cert, skiMethod); err != nil {
+		return nil, err
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, issuer.Cert, pub, issuer.Key)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, err
 	}
 
 	certPEM := new(bytes.Buffer)
@@ -78,18 +319,54 @@ func Sign(ca *types.Cert, csr *types.Cert) *types.Cert {
 		Bytes: certBytes,
 	})
 
-	certPrivKeyPEM := new(bytes.Buffer)
-	pem.Encode(certPrivKeyPEM, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(csr.Key),
-	})
+	var certPrivKeyPEM *bytes.Buffer
+	if key != nil {
+		certPrivKeyPEM, err = keys.EncodePEM(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Self signed certificates have no chain
+	var chain []*x509.Certificate
+	if issuer.Cert != template {
+		chain = append([]*x509.Certificate{issuer.Cert}, issuer.Chain...)
+	}
 
 	certObj := &types.Cert{
 		CertPem: certPEM,
 		KeyPem:  certPrivKeyPEM,
-		Cert:    csr.Cert,
-		Key:     csr.Key,
+		Cert:    cert,
+		Key:     key,
+		Chain:   chain,
 	}
 
-	return certObj
+	return certObj, nil
+}
+
+// setKeyIDs set the subject and authority key identifiers of the template.
+// The authority key identifier is the issuer subject key identifier, computed from the issuer public key
+// with the default method for issuers without one, and the own identifier for self signed certificates.
+func setKeyIDs(template *x509.Certificate, pub crypto.PublicKey, issuer *x509.Certificate, skiMethod keys.SKIMethod) error {
+	if len(template.SubjectKeyId) == 0 {
+		ski, err := keys.SubjectKeyID(pub, skiMethod)
+		if err != nil {
+			return err
+		}
+		template.SubjectKeyId = ski
+	}
+
+	switch {
+	case issuer == template:
+		template.AuthorityKeyId = template.SubjectKeyId
+	case len(issuer.SubjectKeyId) > 0:
+		template.AuthorityKeyId = issuer.SubjectKeyId
+	default:
+		aki, err := keys.SubjectKeyID(issuer.PublicKey, keys.DefaultSKIMethod)
+		if err != nil {
+			return err
+		}
+		template.AuthorityKeyId = aki
+	}
+	return nil
 }
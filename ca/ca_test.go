@@ -0,0 +1,98 @@
+package ca
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/types"
+)
+
+var ecdsaKey = types.KeyOptions{Algorithm: types.ECDSA}
+
+// newTestCA create a root CA valid for an hour, for the tests which don't depend on the CA options
+func newTestCA(t *testing.T) *types.Cert {
+	t.Helper()
+	root, err := CreateCaWithOptions(Options{
+		Subject:  pkix.Name{CommonName: "test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      ecdsaKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestCreateIntermediatePathLen(t *testing.T) {
+	tests := []struct {
+		name string
+		// root and intermediate path length constraints, -1 for no constraint
+		rootPathLen  int
+		interPathLen int
+		wantErr      bool
+		// wantPathLen path length of the intermediate, -1 for no constraint
+		wantPathLen int
+	}{
+		{name: "unconstrained root and intermediate", rootPathLen: -1, interPathLen: -1, wantPathLen: -1},
+		{name: "unconstrained root keeps the intermediate constraint", rootPathLen: -1, interPathLen: 0, wantPathLen: 0},
+		{name: "intermediate inherits the root constraint", rootPathLen: 2, interPathLen: -1, wantPathLen: 1},
+		{name: "last allowed intermediate", rootPathLen: 1, interPathLen: -1, wantPathLen: 0},
+		{name: "lower constraint", rootPathLen: 2, interPathLen: 0, wantPathLen: 0},
+		{name: "constraint not lower than the root", rootPathLen: 1, interPathLen: 1, wantErr: true},
+		{name: "root without intermediates", rootPathLen: 0, interPathLen: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Add(-time.Minute)
+			root, err := CreateCaWithOptions(Options{
+				Subject:        pkix.Name{CommonName: "test root"},
+				Start:          now,
+				Duration:       time.Hour,
+				Key:            ecdsaKey,
+				MaxPathLen:     tt.rootPathLen,
+				MaxPathLenZero: tt.rootPathLen == 0,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			inter, err := CreateIntermediate(root, Options{
+				Subject:        pkix.Name{CommonName: "test intermediate"},
+				Start:          now,
+				Duration:       time.Hour,
+				Key:            ecdsaKey,
+				MaxPathLen:     tt.interPathLen,
+				MaxPathLenZero: tt.interPathLen == 0,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("CreateIntermediate() expected an error, got path length %d", inter.Cert.MaxPathLen)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateIntermediate() unexpected error: %v", err)
+			}
+
+			pathLen := inter.Cert.MaxPathLen
+			if pathLen == 0 && !inter.Cert.MaxPathLenZero {
+				pathLen = -1
+			}
+			if pathLen != tt.wantPathLen {
+				t.Errorf("intermediate path length = %d, want %d", pathLen, tt.wantPathLen)
+			}
+			if len(inter.Chain) != 1 || !inter.Chain[0].Equal(root.Cert) {
+				t.Errorf("intermediate chain is not the root")
+			}
+			roots := x509.NewCertPool()
+			roots.AddCert(root.Cert)
+			if _, err := inter.Cert.Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+				t.Errorf("intermediate doesn't verify against the root: %v", err)
+			}
+		})
+	}
+}
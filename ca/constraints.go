@@ -0,0 +1,176 @@
+package ca
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrNameConstraint the certificate names are not allowed by the name constraints of its issuer
+var ErrNameConstraint = errors.New("name constraint violation")
+
+// NameConstraints RFC 5280 name constraints of a CA, limiting the names of the certificates it can issue.
+// The domain constraints match the domain and its subdomains, or only the subdomains if they start with a dot.
+// The email constraints are a mailbox, or a domain matching the mailboxes of the domain as for the DNS constraints.
+type NameConstraints struct {
+	PermittedDNSDomains     []string
+	ExcludedDNSDomains      []string
+	PermittedIPRanges       []*net.IPNet
+	ExcludedIPRanges        []*net.IPNet
+	PermittedEmailAddresses []string
+	ExcludedEmailAddresses  []string
+	PermittedURIDomains     []string
+	ExcludedURIDomains      []string
+	// Critical mark the name constraints extension critical, as required by RFC 5280
+	Critical bool
+}
+
+// IsEmpty check no constraint is set
+func (nc NameConstraints) IsEmpty() bool {
+	return len(nc.PermittedDNSDomains)+len(nc.ExcludedDNSDomains)+len(nc.PermittedIPRanges)+len(nc.ExcludedIPRanges)+
+		len(nc.PermittedEmailAddresses)+len(nc.ExcludedEmailAddresses)+len(nc.PermittedURIDomains)+len(nc.ExcludedURIDomains) == 0
+}
+
+// Apply set the name constraints on the CA certificate template
+func (nc NameConstraints) Apply(template *x509.Certificate) {
+	template.PermittedDNSDomains = nc.PermittedDNSDomains
+	template.ExcludedDNSDomains = nc.ExcludedDNSDomains
+	template.PermittedIPRanges = nc.PermittedIPRanges
+	template.ExcludedIPRanges = nc.ExcludedIPRanges
+	template.PermittedEmailAddresses = nc.PermittedEmailAddresses
+	template.ExcludedEmailAddresses = nc.ExcludedEmailAddresses
+	template.PermittedURIDomains = nc.PermittedURIDomains
+	template.ExcludedURIDomains = nc.ExcludedURIDomains
+	template.PermittedDNSDomainsCritical = nc.Critical
+}
+
+// ConstraintsOf get the name constraints of a CA certificate
+func ConstraintsOf(cert *x509.Certificate) NameConstraints {
+	return NameConstraints{
+		PermittedDNSDomains:     cert.PermittedDNSDomains,
+		ExcludedDNSDomains:      cert.ExcludedDNSDomains,
+		PermittedIPRanges:       cert.PermittedIPRanges,
+		ExcludedIPRanges:        cert.ExcludedIPRanges,
+		PermittedEmailAddresses: cert.PermittedEmailAddresses,
+		ExcludedEmailAddresses:  cert.ExcludedEmailAddresses,
+		PermittedURIDomains:     cert.PermittedURIDomains,
+		ExcludedURIDomains:      cert.ExcludedURIDomains,
+		Critical:                cert.PermittedDNSDomainsCritical,
+	}
+}
+
+// CheckNameConstraints check the SANs of the certificate template are allowed by the name constraints
+// of the issuer and of every CA of its chain
+func CheckNameConstraints(template *x509.Certificate, issuer *x509.Certificate, chain []*x509.Certificate) error {
+	for _, c := range append([]*x509.Certificate{issuer}, chain...) {
+		if err := ConstraintsOf(c).Check(template); err != nil {
+			return fmt.Errorf("%w of CA %s: %v", ErrNameConstraint, c.Subject, err)
+		}
+	}
+	return nil
+}
+
+// Check check the SANs of the certificate template are allowed by the name constraints
+func (nc NameConstraints) Check(template *x509.Certificate) error {
+	for _, name := range template.DNSNames {
+		if err := checkName("DNS name", name, nc.PermittedDNSDomains, nc.ExcludedDNSDomains, matchDomain); err != nil {
+			return err
+		}
+	}
+	for _, email := range template.EmailAddresses {
+		if err := checkName("email address", email, nc.PermittedEmailAddresses, nc.ExcludedEmailAddresses, matchEmail); err != nil {
+			return err
+		}
+	}
+	for _, uri := range template.URIs {
+		if err := checkURI(uri, nc); err != nil {
+			return err
+		}
+	}
+	for _, ip := range template.IPAddresses {
+		for _, excluded := range nc.ExcludedIPRanges {
+			if excluded.Contains(ip) {
+				return fmt.Errorf("IP address %s is excluded by %s", ip, excluded)
+			}
+		}
+		if len(nc.PermittedIPRanges) == 0 {
+			continue
+		}
+		permitted := false
+		for _, r := range nc.PermittedIPRanges {
+			if r.Contains(ip) {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return fmt.Errorf("IP address %s is not in the permitted ranges", ip)
+		}
+	}
+	return nil
+}
+
+// checkURI check the URI host against the URI domain constraints, the hosts of constrained URIs must be domains
+func checkURI(uri *url.URL, nc NameConstraints) error {
+	if len(nc.PermittedURIDomains)+len(nc.ExcludedURIDomains) == 0 {
+		return nil
+	}
+	host := uri.Hostname()
+	if host == "" {
+		return fmt.Errorf("URI %s has no host to match the URI constraints", uri)
+	}
+	if net.ParseIP(host) != nil {
+		return fmt.Errorf("URI %s host is an IP address, not allowed by the URI constraints", uri)
+	}
+	if err := checkName("URI", host, nc.PermittedURIDomains, nc.ExcludedURIDomains, matchDomain); err != nil {
+		return fmt.Errorf("URI %s: %v", uri, err)
+	}
+	return nil
+}
+
+// checkName check the name matches none of the excluded constraints, and one of the permitted constraints if any
+func checkName(kind string, name string, permitted []string, excluded []string, match func(name, constraint string) bool) error {
+	for _, constraint := range excluded {
+		if match(name, constraint) {
+			return fmt.Errorf("%s %s is excluded by %q", kind, name, constraint)
+		}
+	}
+	if len(permitted) == 0 {
+		return nil
+	}
+	for _, constraint := range permitted {
+		if match(name, constraint) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s is not in the permitted %v", kind, name, permitted)
+}
+
+// matchDomain check the domain is the constraint domain or one of its subdomains,
+// only the subdomains if the constraint starts with a dot
+func matchDomain(domain string, constraint string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	constraint = strings.ToLower(constraint)
+	if constraint == "" {
+		return true
+	}
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(domain, constraint)
+	}
+	return domain == constraint || strings.HasSuffix(domain, "."+constraint)
+}
+
+// matchEmail check the mailbox is the constraint mailbox, or a mailbox of the constraint domain
+func matchEmail(email string, constraint string) bool {
+	if strings.Contains(constraint, "@") {
+		return strings.EqualFold(email, constraint)
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return false
+	}
+	return matchDomain(email[at+1:], constraint)
+}
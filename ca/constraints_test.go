@@ -0,0 +1,214 @@
+package ca
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestMatchDomain(t *testing.T) {
+	tests := []struct {
+		domain     string
+		constraint string
+		want       bool
+	}{
+		{"example.com", "example.com", true},
+		{"api.example.com", "example.com", true},
+		{"a.b.example.com", "example.com", true},
+		{"API.Example.COM", "example.com", true},
+		{"api.example.com.", "example.com", true},
+		{"badexample.com", "example.com", false},
+		{"example.com.evil.org", "example.com", false},
+		{"example.org", "example.com", false},
+		{"example.com", ".example.com", false},
+		{"api.example.com", ".example.com", true},
+		{"a.b.example.com", ".example.com", true},
+		{"badexample.com", ".example.com", false},
+		{"anything.org", "", true},
+	}
+	for _, tt := range tests {
+		if got := matchDomain(tt.domain, tt.constraint); got != tt.want {
+			t.Errorf("matchDomain(%q, %q) = %v, want %v", tt.domain, tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestMatchEmail(t *testing.T) {
+	tests := []struct {
+		email      string
+		constraint string
+		want       bool
+	}{
+		// Mailbox form
+		{"admin@example.com", "admin@example.com", true},
+		{"Admin@Example.com", "admin@example.com", true},
+		{"other@example.com", "admin@example.com", false},
+		{"admin@mail.example.com", "admin@example.com", false},
+		// Host form, like the DNS constraints
+		{"admin@example.com", "example.com", true},
+		{"admin@mail.example.com", "example.com", true},
+		{"admin@badexample.com", "example.com", false},
+		// Subdomains only form
+		{"admin@example.com", ".example.com", false},
+		{"admin@mail.example.com", ".example.com", true},
+		{"not-an-email", "example.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchEmail(tt.email, tt.constraint); got != tt.want {
+			t.Errorf("matchEmail(%q, %q) = %v, want %v", tt.email, tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestNameConstraintsCheck(t *testing.T) {
+	mustCIDR := func(s string) *net.IPNet {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ipNet
+	}
+	mustURL := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	nc := NameConstraints{
+		PermittedDNSDomains:     []string{"example.com", ".example.org"},
+		ExcludedDNSDomains:      []string{"secret.example.com"},
+		PermittedIPRanges:       []*net.IPNet{mustCIDR("10.0.0.0/8"), mustCIDR("fd00::/8")},
+		ExcludedIPRanges:        []*net.IPNet{mustCIDR("10.1.0.0/16")},
+		PermittedEmailAddresses: []string{"example.com", "ops@example.org"},
+		ExcludedEmailAddresses:  []string{"root@example.com"},
+		PermittedURIDomains:     []string{".example.com"},
+		ExcludedURIDomains:      []string{"legacy.example.com"},
+	}
+
+	tests := []struct {
+		name    string
+		cert    x509.Certificate
+		wantErr bool
+	}{
+		{"no names", x509.Certificate{}, false},
+		{"permitted domain", x509.Certificate{DNSNames: []string{"example.com", "api.example.com"}}, false},
+		{"permitted subdomain only", x509.Certificate{DNSNames: []string{"api.example.org"}}, false},
+		{"subdomain only constraint domain", x509.Certificate{DNSNames: []string{"example.org"}}, true},
+		{"not permitted domain", x509.Certificate{DNSNames: []string{"example.net"}}, true},
+		{"excluded wins over permitted", x509.Certificate{DNSNames: []string{"api.secret.example.com"}}, true},
+		{"one name not permitted", x509.Certificate{DNSNames: []string{"api.example.com", "evil.net"}}, true},
+		{"permitted IP", x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.2.3.4"), net.ParseIP("fd00::1")}}, false},
+		{"excluded IP range in permitted range", x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.1.2.3")}}, true},
+		{"IP out of range", x509.Certificate{IPAddresses: []net.IP{net.ParseIP("192.168.1.1")}}, true},
+		{"IPv6 out of range", x509.Certificate{IPAddresses: []net.IP{net.ParseIP("2001:db8::1")}}, true},
+		{"email of permitted host", x509.Certificate{EmailAddresses: []string{"dev@example.com"}}, false},
+		{"permitted mailbox", x509.Certificate{EmailAddresses: []string{"ops@example.org"}}, false},
+		{"other mailbox of permitted mailbox host", x509.Certificate{EmailAddresses: []string{"dev@example.org"}}, true},
+		{"excluded mailbox", x509.Certificate{EmailAddresses: []string{"root@example.com"}}, true},
+		{"permitted URI", x509.Certificate{URIs: []*url.URL{mustURL("spiffe://api.example.com/ns/prod")}}, false},
+		{"excluded URI", x509.Certificate{URIs: []*url.URL{mustURL("https://legacy.example.com/")}}, true},
+		{"URI of subdomain only constraint domain", x509.Certificate{URIs: []*url.URL{mustURL("https://example.com/")}}, true},
+		{"URI without host", x509.Certificate{URIs: []*url.URL{mustURL("urn:example:api")}}, true},
+		{"URI IP host", x509.Certificate{URIs: []*url.URL{mustURL("https://10.2.3.4/")}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := nc.Check(&tt.cert); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := (NameConstraints{}).Check(&x509.Certificate{DNSNames: []string{"any.net"}, URIs: []*url.URL{mustURL("urn:x")}}); err != nil {
+		t.Errorf("no constraints: unexpected error %v", err)
+	}
+}
+
+func TestSignChecksChainNameConstraints(t *testing.T) {
+	root, err := CreateCaWithOptions(Options{
+		Subject:         pkix.Name{CommonName: "constrained root"},
+		Start:           time.Now().Add(-time.Minute),
+		Duration:        time.Hour,
+		Key:             types.KeyOptions{Algorithm: types.ECDSA},
+		NameConstraints: NameConstraints{PermittedDNSDomains: []string{"example.com"}, Critical: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The intermediate has no constraint of its own, the root ones apply to its certificates
+	intermediate, err := CreateIntermediate(root, Options{
+		Subject:  pkix.Name{CommonName: "unconstrained intermediate"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	constrained, err := CreateIntermediate(intermediate, Options{
+		Subject:         pkix.Name{CommonName: "constrained intermediate"},
+		Start:           time.Now().Add(-time.Minute),
+		Duration:        time.Hour,
+		Key:             types.KeyOptions{Algorithm: types.ECDSA},
+		NameConstraints: NameConstraints{ExcludedDNSDomains: []string{"secret.example.com"}, Critical: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		issuer *types.Cert
+		dns    string
+		err    error
+	}{
+		{"root permitted", root, "api.example.com", nil},
+		{"root not permitted", root, "api.example.net", ErrNameConstraint},
+		{"intermediate permitted by the root", intermediate, "api.example.com", nil},
+		{"intermediate not permitted by the root", intermediate, "api.example.net", ErrNameConstraint},
+		{"excluded by the issuing intermediate", constrained, "api.secret.example.com", ErrNameConstraint},
+		{"not permitted by the root of the issuing intermediate", constrained, "api.example.net", ErrNameConstraint},
+		{"permitted by the whole chain", constrained, "api.example.com", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf, err := csr.CreateCSRWithOptions(csr.Options{
+				Subject:  pkix.Name{CommonName: tt.dns},
+				SansDns:  []string{tt.dns},
+				Start:    time.Now(),
+				Duration: time.Hour,
+				Key:      types.KeyOptions{Algorithm: types.ECDSA},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			signed, err := SignCert(tt.issuer, leaf)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			// The Go verifier agrees with the checks done before signing
+			roots := x509.NewCertPool()
+			roots.AddCert(root.Cert)
+			intermediates := x509.NewCertPool()
+			for _, c := range signed.Chain {
+				intermediates.AddCert(c)
+			}
+			if _, err := signed.Cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, DNSName: tt.dns}); err != nil {
+				t.Errorf("signed certificate does not verify: %v", err)
+			}
+		})
+	}
+}
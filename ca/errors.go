@@ -0,0 +1,52 @@
+package ca
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/types"
+)
+
+var (
+	// ErrNoCertificate a required certificate, template or request is missing
+	ErrNoCertificate = errors.New("no certificate")
+	// ErrNotCA the issuer certificate is not a CA, or its key usages don't allow to sign certificates
+	ErrNotCA = errors.New("certificate is not a CA")
+	// ErrCAExpired the issuer certificate is not valid at the start of the new certificate
+	ErrCAExpired = errors.New("CA certificate has expired")
+	// ErrNoValidity the certificate would have an empty or negative validity period
+	ErrNoValidity = errors.New("certificate validity period is empty")
+	// ErrKeyMismatch the private key of the issuer does not match its certificate, same error as keys.ErrKeyMismatch
+	ErrKeyMismatch = keys.ErrKeyMismatch
+)
+
+// CheckIssuer check the issuer can sign a certificate valid from start:
+// the issuer is a CA allowed to sign certificates, valid at start, and its private key matches its certificate.
+func CheckIssuer(issuer *types.Cert, start time.Time) error {
+	if issuer == nil || issuer.Cert == nil {
+		return fmt.Errorf("%w: the CA certificate is required", ErrNoCertificate)
+	}
+	cert := issuer.Cert
+	if !cert.IsCA {
+		return fmt.Errorf("%w: %s", ErrNotCA, cert.Subject)
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("%w: %s has no keyCertSign key usage", ErrNotCA, cert.Subject)
+	}
+	if start.IsZero() {
+		start = time.Now()
+	}
+	if start.After(cert.NotAfter) {
+		return fmt.Errorf("%w: %s expired on %s", ErrCAExpired, cert.Subject, cert.NotAfter.Format(time.RFC3339))
+	}
+	if issuer.Key == nil {
+		return fmt.Errorf("%w: the private key of CA %s is required", keys.ErrNoPrivateKey, cert.Subject)
+	}
+	if err := keys.CheckMatch(issuer.Key, cert.PublicKey); err != nil {
+		return fmt.Errorf("CA %s: %w", cert.Subject, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package ca
+
+import (
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestNilInputsReturnErrors(t *testing.T) {
+	root := newTestCA(t)
+	leaf, err := csr.CreateCSRWithOptions(csr.Options{
+		Subject:  pkix.Name{CommonName: "app"},
+		Start:    time.Now(),
+		Duration: time.Hour,
+		Key:      ecdsaKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"nil csr", func() error { _, err := SignCert(root, nil); return err }, ErrNoCertificate},
+		{"nil csr cert", func() error { _, err := SignCert(root, &types.Cert{Key: leaf.Key}); return err }, ErrNoCertificate},
+		{"nil csr key", func() error { _, err := SignCert(root, &types.Cert{Cert: leaf.Cert}); return err }, keys.ErrNoPrivateKey},
+		{"nil issuer", func() error { _, err := SignCert(nil, leaf); return err }, ErrNoCertificate},
+		{"nil issuer cert", func() error { _, err := SignCert(&types.Cert{Key: root.Key}, leaf); return err }, ErrNoCertificate},
+		{"nil issuer key", func() error { _, err := SignCert(&types.Cert{Cert: root.Cert}, leaf); return err }, keys.ErrNoPrivateKey},
+		{"nil request", func() error { _, err := SignCSR(root, nil, SignOptions{}); return err }, ErrNoCertificate},
+		{"nil parent", func() error { _, err := CreateIntermediate(nil, Options{}); return err }, ErrNoCertificate},
+		{"nil old cert", func() error { _, err := Renew(root, nil, RenewOptions{}); return err }, ErrNoCertificate},
+		{"not a CA", func() error {
+			signed, err := SignCert(root, leaf)
+			if err != nil {
+				return err
+			}
+			_, err = SignCert(signed, leaf)
+			return err
+		}, ErrNotCA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
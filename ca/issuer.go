@@ -0,0 +1,220 @@
+package ca
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/types"
+)
+
+// DefaultIssueDuration validity of the certificates issued by an Issuer when neither the request,
+// the issuer nor the profile set one
+const DefaultIssueDuration = 24 * time.Hour
+
+// Issuer issue certificates in memory with a CA loaded once, without reading or writing any file.
+// An Issuer is safe for concurrent use by multiple goroutines.
+type Issuer struct {
+	ca        *types.Cert
+	profile   profile.Profile
+	duration  time.Duration
+	key       types.KeyOptions
+	skiMethod keys.SKIMethod
+	now       func() time.Time
+
+	// serialMu serialize the calls to the serial source, which may not be safe for concurrent use
+	serialMu sync.Mutex
+	serials  func() (*big.Int, error)
+}
+
+// IssuerOption option of NewIssuer
+type IssuerOption func(*Issuer)
+
+// WithProfile set the default profile of the issued certificates, profile.Peer if not set
+func WithProfile(p profile.Profile) IssuerOption {
+	return func(i *Issuer) {
+		i.profile = p
+	}
+}
+
+// WithDuration set the default validity of the issued certificates, the profile duration or DefaultIssueDuration if not set
+func WithDuration(d time.Duration) IssuerOption {
+	return func(i *Issuer) {
+		i.duration = d
+	}
+}
+
+// WithKeyOptions set the default algorithm and size of the generated private keys, the profile key or RSA 4096 if not set
+func WithKeyOptions(opts types.KeyOptions) IssuerOption {
+	return func(i *Issuer) {
+		i.key = opts
+	}
+}
+
+// WithSKIMethod set the method used to compute the subject key identifiers, keys.DefaultSKIMethod if not set
+func WithSKIMethod(method keys.SKIMethod) IssuerOption {
+	return func(i *Issuer) {
+		i.skiMethod = method
+	}
+}
+
+// WithSerialSource set the source of the serial numbers, like the Next method of a serial registry.
+// Random serials are generated if not set. The calls to the source are serialized by the issuer.
+func WithSerialSource(next func() (*big.Int, error)) IssuerOption {
+	return func(i *Issuer) {
+		i.serials = next
+	}
+}
+
+// WithClock set the function giving the start of the issued certificates, time.Now if not set
+func WithClock(now func() time.Time) IssuerOption {
+	return func(i *Issuer) {
+		i.now = now
+	}
+}
+
+// IssueRequest certificate to issue. When CSR is set, the request is signed and the returned cert has no
+// private key, the subject and SANs of the CSR are used and the other identity fields are ignored.
+type IssueRequest struct {
+	Subject pkix.Name
+	SansDns []string
+	SansIP  []net.IP
+	// SansURI URI SANs, like spiffe://trust.domain/workload
+	SansURI []*url.URL
+	// SansEmail email address SANs
+	SansEmail []string
+	// CSR optional PKCS#10 certificate request to sign instead of generating a new private key
+	CSR *x509.CertificateRequest
+	// Profile of the certificate, the issuer profile if empty
+	Profile profile.Profile
+	// Duration of the certificate, the issuer duration if zero
+	Duration time.Duration
+	// Key algorithm and size of the private key, the issuer key options if empty
+	Key types.KeyOptions
+}
+
+// NewIssuer create an issuer signing with the CA, which must be a valid CA with its private key
+func NewIssuer(ca *types.Cert, opts ...IssuerOption) (*Issuer, error) {
+	i := &Issuer{
+		ca:      ca,
+		profile: profile.Peer,
+		now:     time.Now,
+		serials: serial.New,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	if i.profile.Name == "" {
+		i.profile = profile.Peer
+	}
+	if err := CheckIssuer(ca, i.now()); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// CA get the CA certificate and chain of the issuer
+func (i *Issuer) CA() *types.Cert {
+	return i.ca
+}
+
+// Issue issue a new certificate signed by the CA. The returned cert holds the certificate, the new private key
+// and the CA chain, in memory and pem format. The context is checked before the key generation and the signature.
+// The serial number is taken once the request is checked, a rejected request doesn't consume one.
+func (i *Issuer) Issue(ctx context.Context, req IssueRequest) (*types.Cert, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p := req.Profile
+	if p.Name == "" {
+		p = i.profile
+	}
+	duration := req.Duration
+	if duration == 0 {
+		duration = i.duration
+	}
+	if duration == 0 {
+		duration = p.Duration
+	}
+	if duration == 0 {
+		duration = DefaultIssueDuration
+	}
+
+	// Check the request before taking a serial, a rejected request must not consume one
+	if req.CSR != nil {
+		if err := req.CSR.CheckSignature(); err != nil {
+			return nil, fmt.Errorf("invalid certificate request signature: %v", err)
+		}
+	} else if req.Subject.CommonName == "" && len(req.SansDns)+len(req.SansIP)+len(req.SansURI)+len(req.SansEmail) == 0 {
+		return nil, errors.New("a common name or a SAN is required to identify the certificate")
+	}
+	if duration < 0 {
+		return nil, fmt.Errorf("%w: a positive duration is required, got %s", ErrNoValidity, duration)
+	}
+	start := i.now()
+
+	// Sign the request, the private key stay with the requester
+	if req.CSR != nil {
+		sn, err := i.nextSerial()
+		if err != nil {
+			return nil, err
+		}
+		return SignCSR(i.ca, req.CSR, SignOptions{
+			Profile:      p,
+			Start:        start,
+			Duration:     duration,
+			SerialNumber: sn,
+			SKIMethod:    i.skiMethod,
+		})
+	}
+
+	keyOpts := req.Key
+	if keyOpts == (types.KeyOptions{}) {
+		keyOpts = i.key
+	}
+
+	certObj, err := csr.CreateCSRWithOptions(csr.Options{
+		Subject:   req.Subject,
+		SansDns:   req.SansDns,
+		SansIP:    req.SansIP,
+		SansURI:   req.SansURI,
+		SansEmail: req.SansEmail,
+		Start:     start,
+		Duration:  duration,
+		Key:       keyOpts,
+		Profile:   p,
+		SKIMethod: i.skiMethod,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sn, err := i.nextSerial()
+	if err != nil {
+		return nil, err
+	}
+	certObj.Cert.SerialNumber = sn
+	return SignCert(i.ca, certObj)
+}
+
+// nextSerial get a serial number from the serial source
+func (i *Issuer) nextSerial() (*big.Int, error) {
+	i.serialMu.Lock()
+	defer i.serialMu.Unlock()
+	return i.serials()
+}
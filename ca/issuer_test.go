@@ -0,0 +1,176 @@
+package ca
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/serial"
+)
+
+// countingSerials serial source giving 1, 2, 3... without any lock, the issuer must serialize the calls
+type countingSerials struct {
+	last int64
+}
+
+func (c *countingSerials) next() (*big.Int, error) {
+	c.last++
+	return big.NewInt(c.last), nil
+}
+
+func TestIssueConcurrentSerials(t *testing.T) {
+	root := newTestCA(t)
+	registry, err := serial.OpenRegistry(filepath.Join(t.TempDir(), "ca.serials"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := &countingSerials{}
+
+	tests := []struct {
+		name    string
+		source  func() (*big.Int, error)
+		issued  func() int
+		workers int
+	}{
+		{
+			name:    "counting source",
+			source:  counter.next,
+			issued:  func() int { return int(counter.last) },
+			workers: 50,
+		},
+		{
+			name:    "serial registry",
+			source:  registry.Next,
+			issued:  func() int { return len(registry.Serials()) },
+			workers: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer, err := NewIssuer(root, WithSerialSource(tt.source), WithKeyOptions(ecdsaKey), WithDuration(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every other request has no identity and is rejected
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			serials := map[string]int{}
+			errs := make(chan error, tt.workers)
+			for w := 0; w < tt.workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					req := IssueRequest{}
+					if w%2 == 0 {
+						req.Subject = pkix.Name{CommonName: fmt.Sprintf("worker-%d", w)}
+					}
+					cert, err := issuer.Issue(context.Background(), req)
+					if w%2 != 0 {
+						if err == nil {
+							errs <- fmt.Errorf("worker %d: request without identity issued", w)
+						}
+						return
+					}
+					if err != nil {
+						errs <- fmt.Errorf("worker %d: %v", w, err)
+						return
+					}
+					mu.Lock()
+					serials[serial.Format(cert.Cert.SerialNumber)]++
+					mu.Unlock()
+				}(w)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+
+			valid := (tt.workers + 1) / 2
+			if len(serials) != valid {
+				t.Errorf("%d distinct serials for %d issued certs", len(serials), valid)
+			}
+			for sn, n := range serials {
+				if n != 1 {
+					t.Errorf("serial %s used by %d certs", sn, n)
+				}
+			}
+			if got := tt.issued(); got != valid {
+				t.Errorf("%d serials taken from the source for %d issued certs", got, valid)
+			}
+		})
+	}
+}
+
+func TestIssueInvalidRequestKeepsSerial(t *testing.T) {
+	root := newTestCA(t)
+	req, err := csr.CreateRequest(csr.RequestOptions{Subject: pkix.Name{CommonName: "app"}, Key: ecdsaKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := *req.Csr
+	tampered.Signature = append([]byte(nil), req.Csr.Signature...)
+	tampered.Signature[len(tampered.Signature)-1] ^= 0xff
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		req     IssueRequest
+		wantErr error
+	}{
+		{
+			name: "no common name nor SAN",
+			ctx:  context.Background(),
+			req:  IssueRequest{Subject: pkix.Name{Organization: []string{"team"}}},
+		},
+		{
+			name: "invalid CSR signature",
+			ctx:  context.Background(),
+			req:  IssueRequest{CSR: &tampered},
+		},
+		{
+			name:    "negative duration",
+			ctx:     context.Background(),
+			req:     IssueRequest{Subject: pkix.Name{CommonName: "app"}, Duration: -time.Hour},
+			wantErr: ErrNoValidity,
+		},
+		{
+			name:    "cancelled context",
+			ctx:     cancelled,
+			req:     IssueRequest{Subject: pkix.Name{CommonName: "app"}},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := &countingSerials{}
+			issuer, err := NewIssuer(root, WithSerialSource(counter.next), WithKeyOptions(ecdsaKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = issuer.Issue(tt.ctx, tt.req)
+			if err == nil {
+				t.Fatal("Issue() expected an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Issue() error = %v, want %v", err, tt.wantErr)
+			}
+			if counter.last != 0 {
+				t.Errorf("%d serials taken for a rejected request", counter.last)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package ca
+
+import (
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/subject"
+	"github.com/sundae-party/pki/types"
+)
+
+// RenewOptions options used to renew a certificate
+type RenewOptions struct {
+	Start time.Time
+	// Duration of the new certificate, the validity period of the old certificate if zero
+	Duration time.Duration
+	// SerialNumber of the new certificate, a random serial is generated if nil
+	SerialNumber *big.Int
+	// RotateKey generate a new private key instead of reusing the old one
+	RotateKey bool
+	// Key algorithm and size of the rotated key, the old key algorithm and size if empty.
+	// A size without algorithm is a new size for the old key algorithm.
+	Key types.KeyOptions
+	// Profile applied on the new certificate, the old certificate key usages are kept if empty.
+	// The default subject attributes of the profile are added to the old subject when it lacks them.
+	Profile profile.Profile
+	// SKIMethod method used to compute the subject key identifier,
+	// the method of the old certificate or keys.DefaultSKIMethod if empty
+	SKIMethod keys.SKIMethod
+}
+
+// Renew issue a new certificate with the same subject, SANs and key usages than the old one, signed by the CA.
+// The old private key is reused unless opts.RotateKey is set.
+func Renew(ca *types.Cert, old *types.Cert, opts RenewOptions) (*types.Cert, error) {
+	if old == nil || old.Cert == nil {
+		return nil, fmt.Errorf("%w: the certificate to renew is required", ErrNoCertificate)
+	}
+
+	// Reuse or rotate the private key
+	key := old.Key
+	if opts.RotateKey {
+		keyOpts := opts.Key
+		if keyOpts.Algorithm == "" {
+			oldOpts, err := keys.OptionsOf(old.Cert.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			keyOpts.Algorithm = oldOpts.Algorithm
+			if keyOpts.Size == 0 {
+				keyOpts.Size = oldOpts.Size
+			}
+		}
+		var err error
+		key, err = keys.Generate(keyOpts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: the old private key is required when the key is not rotated", keys.ErrNoPrivateKey)
+	}
+
+	sn := opts.SerialNumber
+	if sn == nil {
+		var err error
+		sn, err = serial.New()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	duration := opts.Duration
+	if duration == 0 {
+		duration = old.Cert.NotAfter.Sub(old.Cert.NotBefore)
+	}
+
+	// Copy the old certificate identity and usages.
+	// The raw subject keeps all the attributes, a parsed subject has no ExtraNames.
+	template := &x509.Certificate{
+		SerialNumber:       sn,
+		Subject:            old.Cert.Subject,
+		RawSubject:         old.Cert.RawSubject,
+		DNSNames:           old.Cert.DNSNames,
+		IPAddresses:        old.Cert.IPAddresses,
+		EmailAddresses:     old.Cert.EmailAddresses,
+		URIs:               old.Cert.URIs,
+		NotBefore:          opts.Start,
+		NotAfter:           opts.Start.Add(duration),
+		KeyUsage:           old.Cert.KeyUsage,
+		ExtKeyUsage:        old.Cert.ExtKeyUsage,
+		UnknownExtKeyUsage: old.Cert.UnknownExtKeyUsage,
+	}
+	if opts.Profile.Name != "" {
+		// Rebuild the subject when the profile adds default attributes, otherwise the raw subject is kept
+		name := old.Cert.Subject
+		name.ExtraNames = subject.ExtraAttributes(old.Cert.Subject)
+		if subject.WithDefaults(name, opts.Profile.Subject).String() != name.String() {
+			template.Subject = name
+			template.RawSubject = nil
+		}
+		opts.Profile.Apply(template)
+		if err := opts.Profile.Check(template); err != nil {
+			return nil, err
+		}
+	}
+
+	skiMethod := opts.SKIMethod
+	if skiMethod == "" {
+		skiMethod = keys.SKIMethodOf(old.Cert)
+	}
+	return sign(template, key.Public(), key, ca, skiMethod)
+}
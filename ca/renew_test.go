@@ -0,0 +1,167 @@
+package ca
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestRenewKeepsSubjectAndUsages(t *testing.T) {
+	root := newTestCA(t)
+	customEKU := asn1.ObjectIdentifier{1, 2, 3, 4, 5}
+	p := profile.Profile{
+		Name:               "custom",
+		KeyUsage:           x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{customEKU},
+	}
+	leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+		Subject: pkix.Name{
+			CommonName: "app",
+			ExtraNames: []pkix.AttributeTypeAndValue{
+				{Type: asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}, Value: "example"},
+				{Type: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: "hello"},
+			},
+		},
+		Start:    time.Now(),
+		Duration: time.Hour,
+		Key:      ecdsaKey,
+		Profile:  p,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := SignCert(root, leafCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := Renew(root, old, RenewOptions{Start: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(renewed.Cert.RawSubject, old.Cert.RawSubject) {
+		t.Errorf("renewed subject %s, want %s", renewed.Cert.Subject, old.Cert.Subject)
+	}
+	if len(renewed.Cert.Subject.Names) != 3 {
+		t.Errorf("renewed subject has %d attributes, want 3", len(renewed.Cert.Subject.Names))
+	}
+	if renewed.Cert.KeyUsage != x509.KeyUsageDigitalSignature {
+		t.Errorf("renewed key usage %v, want digitalSignature", renewed.Cert.KeyUsage)
+	}
+	if len(renewed.Cert.ExtKeyUsage) != 1 || renewed.Cert.ExtKeyUsage[0] != x509.ExtKeyUsageServerAuth {
+		t.Errorf("renewed extended key usages %v, want serverAuth", renewed.Cert.ExtKeyUsage)
+	}
+	if len(renewed.Cert.UnknownExtKeyUsage) != 1 || !renewed.Cert.UnknownExtKeyUsage[0].Equal(customEKU) {
+		t.Errorf("renewed custom extended key usages %v, want %v", renewed.Cert.UnknownExtKeyUsage, customEKU)
+	}
+}
+
+func TestRenewRotateKeySizeKeepsAlgorithm(t *testing.T) {
+	root := newTestCA(t)
+	leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+		Subject:  pkix.Name{CommonName: "app"},
+		Start:    time.Now(),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA, Size: 256},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := SignCert(root, leafCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := Renew(root, old, RenewOptions{Start: time.Now(), RotateKey: true, Key: types.KeyOptions{Size: 384}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, ok := renewed.Cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("renewed key is %T, want an ECDSA key", renewed.Cert.PublicKey)
+	}
+	if pub.Curve.Params().BitSize != 384 {
+		t.Errorf("renewed key curve %s, want P-384", pub.Curve.Params().Name)
+	}
+}
+
+func TestRenewProfileSubjectDefaults(t *testing.T) {
+	root := newTestCA(t)
+	oidDC := asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+	leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+		Subject: pkix.Name{
+			CommonName: "app",
+			Country:    []string{"FR"},
+			ExtraNames: []pkix.AttributeTypeAndValue{{Type: oidDC, Value: "example"}},
+		},
+		Start:    time.Now(),
+		Duration: time.Hour,
+		Key:      ecdsaKey,
+		Profile:  profile.Server,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := SignCert(root, leafCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		profile profile.Profile
+		keepRaw bool
+		org     []string
+		country []string
+	}{
+		{"no profile", profile.Profile{}, true, nil, []string{"FR"}},
+		{"profile without subject", profile.Server, true, nil, []string{"FR"}},
+		{"profile with a set attribute", profile.Profile{Name: "p", Subject: pkix.Name{Country: []string{"DE"}}}, true, nil, []string{"FR"}},
+		{"profile with a new attribute", profile.Profile{Name: "p", Subject: pkix.Name{Organization: []string{"team"}}}, false, []string{"team"}, []string{"FR"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renewed, err := Renew(root, old, RenewOptions{Start: time.Now(), Profile: tt.profile})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keepRaw := bytes.Equal(renewed.Cert.RawSubject, old.Cert.RawSubject); keepRaw != tt.keepRaw {
+				t.Errorf("renewed subject %s, raw subject kept %v, want %v", renewed.Cert.Subject, keepRaw, tt.keepRaw)
+			}
+			got := renewed.Cert.Subject
+			if got.CommonName != "app" || !equalStrings(got.Country, tt.country) || !equalStrings(got.Organization, tt.org) {
+				t.Errorf("renewed subject %s, want CN app, C %v and O %v", got, tt.country, tt.org)
+			}
+			var dc []interface{}
+			for _, attr := range got.Names {
+				if attr.Type.Equal(oidDC) {
+					dc = append(dc, attr.Value)
+				}
+			}
+			if len(dc) != 1 || dc[0] != "example" {
+				t.Errorf("renewed DC attributes %v, want [example]", dc)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
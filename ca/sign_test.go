@@ -0,0 +1,99 @@
+package ca
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/profile"
+)
+
+func TestSignCSRKeepsExtraSubjectAttributes(t *testing.T) {
+	root := newTestCA(t)
+	oidDC := asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+	oidCustom := asn1.ObjectIdentifier{1, 2, 3, 4}
+	req, err := csr.CreateRequest(csr.RequestOptions{
+		Subject: pkix.Name{
+			CommonName:   "app",
+			Organization: []string{"team"},
+			ExtraNames: []pkix.AttributeTypeAndValue{
+				{Type: oidDC, Value: asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte("example")}},
+				{Type: oidCustom, Value: "hello"},
+			},
+		},
+		Key: ecdsaKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := SignCSR(root, req.Csr, SignOptions{Profile: profile.Server, Start: time.Now(), Duration: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string][]interface{}{}
+	for _, attr := range cert.Cert.Subject.Names {
+		values[attr.Type.String()] = append(values[attr.Type.String()], attr.Value)
+	}
+	want := map[string]string{"2.5.4.3": "app", "2.5.4.10": "team", oidDC.String(): "example", oidCustom.String(): "hello"}
+	if len(values) != len(want) {
+		t.Errorf("subject %s has attributes %v, want %v", cert.Cert.Subject, values, want)
+	}
+	for oid, v := range want {
+		if len(values[oid]) != 1 || values[oid][0] != v {
+			t.Errorf("subject attribute %s = %v, want [%s]", oid, values[oid], v)
+		}
+	}
+
+	// DC values are IA5Strings
+	if !bytes.Contains(cert.Cert.RawSubject, append([]byte{asn1.TagIA5String, 7}, "example"...)) {
+		t.Errorf("DC attribute is not encoded as an IA5String")
+	}
+}
+
+func TestSignCSRValidity(t *testing.T) {
+	root := newTestCA(t)
+	req, err := csr.CreateRequest(csr.RequestOptions{Subject: pkix.Name{CommonName: "app"}, Key: ecdsaKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Second).Truncate(time.Second)
+
+	tests := []struct {
+		name     string
+		opts     SignOptions
+		duration time.Duration
+		err      error
+	}{
+		{"duration", SignOptions{Profile: profile.Server, Start: start, Duration: time.Hour}, time.Hour, nil},
+		{"profile duration", SignOptions{Profile: profile.Profile{Name: "p", Duration: 2 * time.Hour}, Start: start}, 2 * time.Hour, nil},
+		{"zero start is now", SignOptions{Profile: profile.Server, Duration: time.Hour}, time.Hour, nil},
+		{"zero duration", SignOptions{Profile: profile.Server, Start: start}, 0, ErrNoValidity},
+		{"negative duration", SignOptions{Profile: profile.Server, Start: start, Duration: -time.Hour}, 0, ErrNoValidity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := SignCSR(root, req.Csr, tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !tt.opts.Start.IsZero() && !cert.Cert.NotBefore.Equal(tt.opts.Start) {
+				t.Errorf("not before %s, want %s", cert.Cert.NotBefore, tt.opts.Start)
+			}
+			if tt.opts.Start.IsZero() && time.Since(cert.Cert.NotBefore) > time.Minute {
+				t.Errorf("not before %s, want now", cert.Cert.NotBefore)
+			}
+			if got := cert.Cert.NotAfter.Sub(cert.Cert.NotBefore); got != tt.duration {
+				t.Errorf("validity %s, want %s", got, tt.duration)
+			}
+		})
+	}
+}
@@ -16,16 +16,17 @@ limitations under the License.
 package cmd
 
 import (
-	"crypto/x509/pkix"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/subject"
+	"github.com/sundae-party/pki/utils"
 )
 
 // caCmd represents the ca command
@@ -35,16 +36,12 @@ var caCmd = &cobra.Command{
 	Long:  `Create new self signed CA.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		cn, err := cmd.Flags().GetString("cn")
+		// Build CA subject from flags
+		caSubj, err := subjectFromFlags(cmd, "cn")
 		if err != nil {
 			return err
 		}
 
-		// Buil CA with given CN
-		caSubj := &pkix.Name{
-			CommonName: cn,
-		}
-
 		timeExp, err := cmd.Flags().GetInt("exp")
 		if err != nil {
 			return err
@@ -54,11 +51,74 @@ var caCmd = &cobra.Command{
 		stringTimeExp := fmt.Sprintf("%dh", timeExp)
 		yearDuration, err := time.ParseDuration(stringTimeExp)
 		if err != nil {
-			log.Fatalln(err)
+			return err
+		}
+
+		// Get CA key algorithm and size
+		keyOpts, err := keyOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get the profile, its key, validity, usages and subject attributes are used unless set with the flags
+		p, err := profileFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		applyProfileDefaults(cmd, p, &keyOpts, &yearDuration)
+		if err := applyUsageFlags(cmd, &p); err != nil {
+			return err
+		}
+
+		// Get the subject key identifier method
+		skiMethod, err := skiMethodFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		caSubj = subject.WithDefaults(caSubj, p.Subject)
+
+		// Get CA key encryption
+		keyEnc, err := keyEncryptionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get CA path length constraint
+		maxPathLen, err := cmd.Flags().GetInt("maxPathLen")
+		if err != nil {
+			return err
+		}
+
+		// Get CA name constraints
+		constraints, err := nameConstraintsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get SPIFFE trust domain pinned by the CA
+		trustDomain, err := cmd.Flags().GetString("spiffeTrustDomain")
+		if err != nil {
+			return err
 		}
 
 		// Gen new CA
-		rootCa := ca.CreateCa(*caSubj, time.Now(), yearDuration)
+		rootCa, err := ca.CreateCaWithOptions(ca.Options{
+			Subject:            caSubj,
+			Start:              time.Now(),
+			Duration:           yearDuration,
+			Key:                keyOpts,
+			MaxPathLen:         maxPathLen,
+			MaxPathLenZero:     maxPathLen == 0,
+			KeyUsage:           p.KeyUsage,
+			ExtKeyUsage:        p.ExtKeyUsage,
+			UnknownExtKeyUsage: p.UnknownExtKeyUsage,
+			NameConstraints:    constraints,
+			SPIFFETrustDomain:  trustDomain,
+			SKIMethod:          skiMethod,
+		})
+		if err != nil {
+			return err
+		}
 
 		// Create ssl folder
 		dest, err := cmd.Flags().GetString("dest")
@@ -93,11 +153,31 @@ var caCmd = &cobra.Command{
 		}
 
 		// Write CA key files
-		err = ioutil.WriteFile(keyPath, rootCa.KeyPem.Bytes(), 0600)
+		keyPem, err := keyEnc.EncodePEM(rootCa.Key)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(keyPath, keyPem.Bytes(), 0600)
+		if err != nil {
+			return err
+		}
+
+		// Record the CA serial in the registry created next to the CA cert
+		registry, err := serial.OpenRegistry(serial.RegistryPath(certPath))
+		if err != nil {
+			return err
+		}
+		err = registry.Register(rootCa.Cert.SerialNumber)
+		if err != nil {
+			return err
+		}
+
+		// Record the CA in its own inventory
+		requester, err := cmd.Flags().GetString("requester")
 		if err != nil {
 			return err
 		}
-		return nil
+		return utils.RecordIssuance("", certPath, rootCa.Cert, "ca", requester, utils.CertFiles{CertPath: certPath, KeyPath: keyPath})
 	},
 }
 
@@ -110,12 +190,21 @@ func init() {
 	// and all subcommands, e.g.:
 	caCmd.Flags().StringP("dest", "d", "ssl", "Destination where CA cert and key files will be created. (default is ./ssl)")
 
-	caCmd.Flags().String("cn", "", "Common Name to add in the CA.")
-	caCmd.MarkFlagRequired("cn")
+	caCmd.Flags().String("cn", "", "Common Name to add in the CA. Required unless set in --subject.")
+	addSubjectFlags(caCmd)
 
 	caCmd.Flags().String("certName", "ca.pem", "CA cert file name. (default is ca.pem)")
 	caCmd.Flags().String("keyName", "ca.key", "CA key file name. (default is ca.key)")
 	caCmd.Flags().Int("exp", 87600, "Time when the cert will expire from now. (default is 87600h - 10 years)")
+	addKeyFlags(caCmd)
+	addSKIMethodFlag(caCmd, "sha1")
+	addKeyOutFlags(caCmd)
+	addProfileFlag(caCmd, "")
+	addUsageFlags(caCmd)
+	caCmd.Flags().String("requester", "", "Requester recorded in the CA inventory. (default is the current user)")
+	addNameConstraintsFlags(caCmd)
+	caCmd.Flags().String("spiffeTrustDomain", "", "SPIFFE trust domain of the CA, its name constraints only permit SPIFFE IDs of this trust domain.")
+	caCmd.Flags().Int("maxPathLen", -1, "Maximum number of intermediate CAs allowed below this CA, -1 for no limit. (default is -1)")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -0,0 +1,109 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/utils"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check <file|dir|glob>...",
+	Short: "Check certificates expiry",
+	Long: `Parse every certificate of the given pem or der files, directories and glob patterns, including chains and bundles,
+and report the certificates expired or expiring within the threshold. No private key is needed.
+
+Exit codes: 0 all certificates are ok, 1 the command failed, 2 some are expiring, 3 some are expired,
+4 some files can't be checked.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		within, err := cmd.Flags().GetInt("within")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			return err
+		}
+		if output != "text" && output != "json" {
+			return fmt.Errorf("unsupported output %q, expected text or json", output)
+		}
+
+		checks, err := utils.CheckExpiry(args, time.Duration(within)*time.Hour, time.Now())
+		if err != nil {
+			return err
+		}
+		utils.SortByExpiry(checks)
+
+		// Only report the certificates needing attention unless all is set
+		report := checks
+		if !all {
+			report = []utils.ExpiryCheck{}
+			for _, check := range checks {
+				if check.Status != utils.ExpiryOk {
+					report = append(report, check)
+				}
+			}
+		}
+
+		if output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, check := range report {
+				if check.Status == utils.ExpiryError {
+					fmt.Fprintf(w, "%s\t%s\t\t\t%s\n", check.Status, check.Path, check.Error)
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%s[%d]\t%s\t%s\t%s\n", check.Status, check.Path, check.Index, check.NotAfter.Format(time.RFC3339), check.ExpiresIn, check.Subject)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%d certificates checked, %d reported\n", len(checks), len(report))
+		}
+
+		if code := utils.ExpiryExitCode(checks); code != 0 {
+			return exitWithCode(cmd, code)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().Int("within", 720, "Report the certificates expiring within this number of hours. (default is 720h - 30 days)")
+	checkCmd.Flags().StringP("output", "o", "text", "Output format: text or json. (default is text)")
+	checkCmd.Flags().Bool("all", false, "Report all the certificates, not only the expiring and expired ones.")
+}
@@ -17,11 +17,11 @@ package cmd
 
 import (
 	"fmt"
-	"log"
 	"net"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/sundae-party/pki/profile"
 	"github.com/sundae-party/pki/utils"
 )
 
@@ -42,8 +42,14 @@ var clientCertCmd = &cobra.Command{
 			return err
 		}
 
-		// Get CN from flag
-		cn, err := cmd.Flags().GetString("certCn")
+		// Get the subject from flags
+		subj, err := subjectFromFlags(cmd, "certCn")
+		if err != nil {
+			return err
+		}
+
+		// Get URI and email sans from flags
+		sansUri, sansEmail, err := uriEmailSansFromFlags(cmd)
 		if err != nil {
 			return err
 		}
@@ -56,7 +62,7 @@ var clientCertCmd = &cobra.Command{
 		formatedDurationString := fmt.Sprintf("%dh", durationString)
 		duration, err := time.ParseDuration(formatedDurationString)
 		if err != nil {
-			log.Fatalln(err)
+			return err
 		}
 
 		// Get destination folder
@@ -75,7 +81,83 @@ var clientCertCmd = &cobra.Command{
 			return err
 		}
 
-		return utils.CreateCertFromCAFile(caKeyPath, caCertPath, cn, duration, []string{}, []net.IP{}, dest, certFileName, keyFileName)
+		// Get key algorithm and size from flags
+		keyOpts, err := keyOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get the issuance profile, its key and validity are used unless set with the flags
+		p, err := profileFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		applyProfileDefaults(cmd, p, &keyOpts, &duration)
+		if err := applyUsageFlags(cmd, &p); err != nil {
+			return err
+		}
+
+		// Get the subject key identifier method
+		skiMethod, err := skiMethodFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get CA serial registry from flags
+		serialRegistry, err := cmd.Flags().GetString("serialRegistry")
+		if err != nil {
+			return err
+		}
+
+		// Get CA chain and full chain file name from flags
+		caChainPath, err := cmd.Flags().GetString("caChain")
+		if err != nil {
+			return err
+		}
+		chainFileName, err := cmd.Flags().GetString("chainFileName")
+		if err != nil {
+			return err
+		}
+
+		// Get CA inventory and requester from flags
+		inventoryPath, requester, err := inventoryFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get CA key passphrase and new key encryption from flags
+		caKeyPass, err := passphraseFromFlag(cmd, "caKeyPass", "Enter passphrase of the CA private key", false)
+		if err != nil {
+			return err
+		}
+		keyEnc, err := keyEncryptionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		return utils.CreateCert(utils.CertOptions{
+			CAKeyPath:       caKeyPath,
+			CACertPath:      caCertPath,
+			Subject:         subj,
+			SansUri:         sansUri,
+			SansEmail:       sansEmail,
+			Duration:        duration,
+			SansDns:         []string{},
+			SansIp:          []net.IP{},
+			Dest:            dest,
+			CertFileName:    certFileName,
+			KeyFileName:     keyFileName,
+			Key:             keyOpts,
+			Profile:         p,
+			SerialRegistry:  serialRegistry,
+			CAChainPath:     caChainPath,
+			ChainFileName:   chainFileName,
+			Inventory:       inventoryPath,
+			Requester:       requester,
+			CAKeyPassphrase: caKeyPass,
+			KeyEncryption:   keyEnc,
+			SKIMethod:       skiMethod,
+		})
 	},
 }
 
@@ -85,14 +167,21 @@ func init() {
 	// CA key to signe cert
 	clientCertCmd.Flags().String("caKey", "", "CA Key path used to sign the new certificate.")
 	clientCertCmd.MarkFlagRequired("caKey")
+	addCAKeyPassFlag(clientCertCmd)
 
 	// CA cert to signe cert
 	clientCertCmd.Flags().String("caCert", "", "CA Cert path used to sign the new certificate.")
 	clientCertCmd.MarkFlagRequired("caCert")
 
+	// Chain of the CA when signing with an intermediate CA
+	clientCertCmd.Flags().String("caChain", "", "Issuer chain of the CA used to sign the new certificate, when the CA is an intermediate.")
+
 	// CN
-	clientCertCmd.Flags().String("certCn", "", "Common Name to add in the new cert.")
-	clientCertCmd.MarkFlagRequired("certCn")
+	clientCertCmd.Flags().String("certCn", "", "Common Name to add in the new cert. Required unless set in --subject.")
+	addSubjectFlags(clientCertCmd)
+
+	// SANS URI and email
+	addURIEmailSansFlags(clientCertCmd)
 
 	// Destination
 	clientCertCmd.Flags().StringP("dest", "d", "ssl", "Destination where the cert and key files will be created. (default is ./ssl)")
@@ -100,7 +189,21 @@ func init() {
 	// Files name
 	clientCertCmd.Flags().String("certFileName", "client.pem", "The cert file name. (default is srv.pem)")
 	clientCertCmd.Flags().String("keyFileName", "client.key", "The key file name. (default is srv.key)")
+	clientCertCmd.Flags().String("chainFileName", "", "The full chain file name, the cert followed by the CA chain. Not written if empty.")
 
 	// Duration
 	clientCertCmd.Flags().Int("exp", 87600, "Time when the cert will expire from now. (default is 87600h - 10 years)")
+
+	// Key algorithm and size
+	addKeyFlags(clientCertCmd)
+	addSKIMethodFlag(clientCertCmd, "sha1")
+	addKeyOutFlags(clientCertCmd)
+
+	// Profile
+	addProfileFlag(clientCertCmd, profile.Client.Name)
+	addUsageFlags(clientCertCmd)
+
+	// Serial registry
+	clientCertCmd.Flags().String("serialRegistry", "", "Serial registry file of the CA. (default is the CA cert path with the .serials extension)")
+	addInventoryFlags(clientCertCmd)
 }
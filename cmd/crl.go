@@ -0,0 +1,118 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/crl"
+	"github.com/sundae-party/pki/utils"
+)
+
+// crlCmd represents the crl command
+var crlCmd = &cobra.Command{
+	Use:   "crl",
+	Short: "Generate a new CRL for a CA",
+	Long:  `Generate a new numbered CRL signed by the CA with all the certificates of its revocation list.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get CA info from flags
+		caKeyPath, err := cmd.Flags().GetString("caKey")
+		if err != nil {
+			return err
+		}
+		caCertPath, err := cmd.Flags().GetString("caCert")
+		if err != nil {
+			return err
+		}
+		listPath, err := cmd.Flags().GetString("revocationList")
+		if err != nil {
+			return err
+		}
+		if listPath == "" {
+			listPath = crl.ListPath(caCertPath)
+		}
+
+		// Load CA
+		caKeyPass, err := passphraseFromFlag(cmd, "caKeyPass", "Enter passphrase of the CA private key", false)
+		if err != nil {
+			return err
+		}
+		caCert, err := utils.LoadCertFromFile(caKeyPath, caKeyPass, caCertPath)
+		if err != nil {
+			return err
+		}
+
+		// Get the CRL validity
+		nextUpdate, err := cmd.Flags().GetInt("nextUpdate")
+		if err != nil {
+			return err
+		}
+		validity, err := time.ParseDuration(fmt.Sprintf("%dh", nextUpdate))
+		if err != nil {
+			return err
+		}
+
+		// Create destination folder
+		dest, err := cmd.Flags().GetString("dest")
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			err := os.Mkdir(dest, 0700)
+			if err != nil {
+				return err
+			}
+		}
+		crlFileName, err := cmd.Flags().GetString("crlFileName")
+		if err != nil {
+			return err
+		}
+
+		// Gen and write the CRL, the new CRL number is saved with the list
+		return crl.UpdateList(listPath, func(list *crl.List) error {
+			crlPem, err := crl.Generate(caCert, list, time.Now(), validity)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(fmt.Sprintf("%s/%s", dest, crlFileName), crlPem.Bytes(), 0644)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(crlCmd)
+
+	// CA used to sign the CRL
+	crlCmd.Flags().String("caKey", "", "CA Key path used to sign the CRL.")
+	crlCmd.MarkFlagRequired("caKey")
+	addCAKeyPassFlag(crlCmd)
+	crlCmd.Flags().String("caCert", "", "CA Cert path used to sign the CRL.")
+	crlCmd.MarkFlagRequired("caCert")
+	crlCmd.Flags().String("revocationList", "", "Revocation list file of the CA. (default is the CA cert path with the .revoked extension)")
+
+	// Destination
+	crlCmd.Flags().StringP("dest", "d", "ssl", "Destination where the CRL file will be created. (default is ./ssl)")
+	crlCmd.Flags().String("crlFileName", "ca.crl", "The CRL file name. (default is ca.crl)")
+
+	// Validity
+	crlCmd.Flags().Int("nextUpdate", 168, "Time until the next CRL update from now. (default is 168h - 7 days)")
+}
@@ -0,0 +1,145 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/csr"
+)
+
+// csrCmd represents the csr command
+var csrCmd = &cobra.Command{
+	Use:   "csr",
+	Short: "Create new certificate request and key",
+	Long: `Create a new PKCS#10 certificate request and its private key on the requesting host.
+The request can be signed by a CA with the sign command, the private key never leave this host.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get the subject from flags
+		subj, err := subjectFromFlags(cmd, "certCn")
+		if err != nil {
+			return err
+		}
+
+		// Get sans from flags
+		sansDns, err := cmd.Flags().GetStringSlice("sansDns")
+		if err != nil {
+			return err
+		}
+		sansIp, err := cmd.Flags().GetIPSlice("sansIp")
+		if err != nil {
+			return err
+		}
+		sansUri, sansEmail, err := uriEmailSansFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get key algorithm and size from flags
+		keyOpts, err := keyOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get key encryption from flags
+		keyEnc, err := keyEncryptionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Gen new certificate request
+		req, err := csr.CreateRequest(csr.RequestOptions{
+			Subject:   subj,
+			SansDns:   sansDns,
+			SansIP:    sansIp,
+			SansURI:   sansUri,
+			SansEmail: sansEmail,
+			Key:       keyOpts,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Create destination folder
+		dest, err := cmd.Flags().GetString("dest")
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			err := os.Mkdir(dest, 0700)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Build csr & key dest path
+		csrFileName, err := cmd.Flags().GetString("csrFileName")
+		if err != nil {
+			return err
+		}
+		keyFileName, err := cmd.Flags().GetString("keyFileName")
+		if err != nil {
+			return err
+		}
+		csrPath := fmt.Sprintf("%s/%s", dest, csrFileName)
+		keyPath := fmt.Sprintf("%s/%s", dest, keyFileName)
+
+		// Write csr and key files
+		err = ioutil.WriteFile(csrPath, req.CsrPem.Bytes(), 0600)
+		if err != nil {
+			return err
+		}
+		keyPem, err := keyEnc.EncodePEM(req.Key)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(keyPath, keyPem.Bytes(), 0600)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(csrCmd)
+
+	// CN
+	csrCmd.Flags().String("certCn", "", "Common Name to add in the certificate request. Required unless set in --subject.")
+	addSubjectFlags(csrCmd)
+
+	// Destination
+	csrCmd.Flags().StringP("dest", "d", "ssl", "Destination where the csr and key files will be created. (default is ./ssl)")
+
+	// Files name
+	csrCmd.Flags().String("csrFileName", "srv.csr", "The certificate request file name. (default is srv.csr)")
+	csrCmd.Flags().String("keyFileName", "srv.key", "The key file name. (default is srv.key)")
+
+	// Key algorithm and size
+	addKeyFlags(csrCmd)
+	addKeyOutFlags(csrCmd)
+
+	// SANS DSN
+	csrCmd.Flags().StringSlice("sansDns", []string{}, "Additional dns in SANS")
+
+	// SANS IP
+	csrCmd.Flags().IPSlice("sansIp", []net.IP{}, "Additional IPs in SANS")
+
+	// SANS URI and email
+	addURIEmailSansFlags(csrCmd)
+}
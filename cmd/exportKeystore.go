@@ -0,0 +1,132 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/store"
+	"github.com/sundae-party/pki/utils"
+)
+
+// exportKeystoreCmd represents the export keystore command
+var exportKeystoreCmd = &cobra.Command{
+	Use:   "keystore",
+	Short: "Export a cert, key and CA chain in a Java key store",
+	Long: `Write a Java key store with a single key entry holding the private key, the cert and its CA chain,
+to be used as key store of JVM TLS servers and mTLS clients.
+The PKCS#12 store type is the default of Java 9+, the JKS type is supported by every Java version.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get cert, key and chain paths from flags
+		certPath, err := cmd.Flags().GetString("cert")
+		if err != nil {
+			return err
+		}
+		keyPath, err := cmd.Flags().GetString("key")
+		if err != nil {
+			return err
+		}
+		chainPath, err := cmd.Flags().GetString("chain")
+		if err != nil {
+			return err
+		}
+		keyPass, err := passphraseFromFlag(cmd, "keyPass", "Enter passphrase of the private key", false)
+		if err != nil {
+			return err
+		}
+
+		// Get key store options from flags
+		storeType, err := storeTypeFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		outPath, err := cmd.Flags().GetString("out")
+		if err != nil {
+			return err
+		}
+		if outPath == "" {
+			outPath = fmt.Sprintf("keystore.%s", storeFileExt(storeType))
+		}
+		alias, err := cmd.Flags().GetString("alias")
+		if err != nil {
+			return err
+		}
+		storePass, err := passphraseFromFlag(cmd, "storePass", "Enter key store password", true)
+		if err != nil {
+			return err
+		}
+		entryPass, err := passphraseFromFlag(cmd, "entryPass", "Enter key entry password", true)
+		if err != nil {
+			return err
+		}
+
+		return utils.ExportKeyStoreFile(utils.KeyStoreExportOptions{
+			CertPath:      certPath,
+			KeyPath:       keyPath,
+			KeyPassphrase: keyPass,
+			ChainPath:     chainPath,
+			OutPath:       outPath,
+			Alias:         alias,
+			StorePassword: storePass,
+			KeyPassword:   entryPass,
+			Type:          storeType,
+		})
+	},
+}
+
+// addStoreTypeFlag add the flag used to choose the Java store type
+func addStoreTypeFlag(cmd *cobra.Command) {
+	cmd.Flags().String("storeType", "pkcs12", "Java store type: pkcs12 or jks. (default is pkcs12)")
+}
+
+// storeTypeFromFlags get the Java store type from the flag added by addStoreTypeFlag
+func storeTypeFromFlags(cmd *cobra.Command) (store.KeystoreType, error) {
+	name, err := cmd.Flags().GetString("storeType")
+	if err != nil {
+		return "", err
+	}
+	return store.ParseKeystoreType(name)
+}
+
+// storeFileExt get the usual file extension of a Java store type
+func storeFileExt(storeType store.KeystoreType) string {
+	if storeType == store.JKS {
+		return "jks"
+	}
+	return "p12"
+}
+
+func init() {
+	exportCmd.AddCommand(exportKeystoreCmd)
+
+	// Cert to export
+	exportKeystoreCmd.Flags().String("cert", "", "Cert path, may be followed by its CA chain.")
+	exportKeystoreCmd.MarkFlagRequired("cert")
+	exportKeystoreCmd.Flags().String("key", "", "Key path.")
+	exportKeystoreCmd.MarkFlagRequired("key")
+	exportKeystoreCmd.Flags().String("keyPass", "", "Passphrase source of the private key when it's encrypted: "+passphraseSourceUsage+".")
+	exportKeystoreCmd.Flags().String("chain", "", "CA chain path, the intermediate CAs and optionally the root CA. (default is the chain following the cert in the cert file)")
+
+	// Key store
+	exportKeystoreCmd.Flags().StringP("out", "o", "", "Key store file path. (default is keystore.p12 or keystore.jks)")
+	addStoreTypeFlag(exportKeystoreCmd)
+	exportKeystoreCmd.Flags().String("alias", "", "Alias of the key entry. (default is the lower case common name of the cert)")
+	exportKeystoreCmd.Flags().String("storePass", "prompt", "Password source of the key store: "+passphraseSourceUsage+". (default is prompt)")
+	exportKeystoreCmd.Flags().String("entryPass", "", "Password source of the key entry, jks only: "+passphraseSourceUsage+". (default is the store password)")
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/store"
+	"github.com/sundae-party/pki/utils"
+)
+
+// exportP12Cmd represents the export p12 command
+var exportP12Cmd = &cobra.Command{
+	Use:   "p12",
+	Short: "Export a cert, key and CA chain in a PKCS#12 file",
+	Long: `Bundle a cert, its private key and its CA chain in a password protected PKCS#12 (.p12/.pfx) file.
+The modern encryption (AES-256 and PBKDF2) is supported by OpenSSL 3, Java 12+ and recent macOS.
+Use the legacy encryption (3DES) for OpenSSL 1.x, older Java and macOS/iOS keychains,
+and legacy-rc2 for the oldest Windows and Java versions.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get cert, key and chain paths from flags
+		certPath, err := cmd.Flags().GetString("cert")
+		if err != nil {
+			return err
+		}
+		keyPath, err := cmd.Flags().GetString("key")
+		if err != nil {
+			return err
+		}
+		chainPath, err := cmd.Flags().GetString("chain")
+		if err != nil {
+			return err
+		}
+		keyPass, err := passphraseFromFlag(cmd, "keyPass", "Enter passphrase of the private key", false)
+		if err != nil {
+			return err
+		}
+
+		// Get PKCS#12 file options from flags
+		outPath, err := cmd.Flags().GetString("out")
+		if err != nil {
+			return err
+		}
+		encryptionName, err := cmd.Flags().GetString("encryption")
+		if err != nil {
+			return err
+		}
+		encryption, err := store.ParseP12Encryption(encryptionName)
+		if err != nil {
+			return err
+		}
+		password, err := passphraseFromFlag(cmd, "pass", "Enter PKCS#12 password", true)
+		if err != nil {
+			return err
+		}
+
+		return utils.ExportP12File(utils.P12ExportOptions{
+			CertPath:      certPath,
+			KeyPath:       keyPath,
+			KeyPassphrase: keyPass,
+			ChainPath:     chainPath,
+			OutPath:       outPath,
+			Password:      password,
+			Encryption:    encryption,
+		})
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportP12Cmd)
+
+	// Cert to export
+	exportP12Cmd.Flags().String("cert", "", "Cert path, may be followed by its CA chain.")
+	exportP12Cmd.MarkFlagRequired("cert")
+	exportP12Cmd.Flags().String("key", "", "Key path.")
+	exportP12Cmd.MarkFlagRequired("key")
+	exportP12Cmd.Flags().String("keyPass", "", "Passphrase source of the private key when it's encrypted: "+passphraseSourceUsage+".")
+	exportP12Cmd.Flags().String("chain", "", "CA chain path, the intermediate CAs and optionally the root CA. (default is the chain following the cert in the cert file)")
+
+	// PKCS#12 file
+	exportP12Cmd.Flags().StringP("out", "o", "cert.p12", "PKCS#12 file path. (default is cert.p12)")
+	exportP12Cmd.Flags().String("pass", "prompt", "Password source of the PKCS#12 file: "+passphraseSourceUsage+". (default is prompt)")
+	exportP12Cmd.Flags().String("encryption", "modern", "PKCS#12 encryption: modern, legacy or legacy-rc2. (default is modern)")
+}
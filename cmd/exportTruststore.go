@@ -0,0 +1,83 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/utils"
+)
+
+// exportTruststoreCmd represents the export truststore command
+var exportTruststoreCmd = &cobra.Command{
+	Use:   "truststore",
+	Short: "Export CA certs in a Java trust store",
+	Long: `Write a Java trust store with a trusted certificate entry for each CA cert found in the cert files,
+to be used as trust store of JVM TLS clients and mTLS servers.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get CA certs and aliases from flags
+		certPaths, err := cmd.Flags().GetStringSlice("cert")
+		if err != nil {
+			return err
+		}
+		aliases, err := cmd.Flags().GetStringSlice("alias")
+		if err != nil {
+			return err
+		}
+
+		// Get trust store options from flags
+		storeType, err := storeTypeFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		outPath, err := cmd.Flags().GetString("out")
+		if err != nil {
+			return err
+		}
+		if outPath == "" {
+			outPath = fmt.Sprintf("truststore.%s", storeFileExt(storeType))
+		}
+		storePass, err := passphraseFromFlag(cmd, "storePass", "Enter trust store password", true)
+		if err != nil {
+			return err
+		}
+
+		return utils.ExportTrustStoreFile(utils.TrustStoreExportOptions{
+			CertPaths: certPaths,
+			Aliases:   aliases,
+			OutPath:   outPath,
+			Password:  storePass,
+			Type:      storeType,
+		})
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportTruststoreCmd)
+
+	// CA certs to trust
+	exportTruststoreCmd.Flags().StringSlice("cert", []string{}, "CA cert paths, each file may hold several certs.")
+	exportTruststoreCmd.MarkFlagRequired("cert")
+	exportTruststoreCmd.Flags().StringSlice("alias", []string{}, "Aliases of the CA certs, in file order. (default is the lower case common name of each cert)")
+
+	// Trust store
+	exportTruststoreCmd.Flags().StringP("out", "o", "", "Trust store file path. (default is truststore.p12 or truststore.jks)")
+	addStoreTypeFlag(exportTruststoreCmd)
+	exportTruststoreCmd.Flags().String("storePass", "prompt", "Password source of the trust store: "+passphraseSourceUsage+". (default is prompt)")
+}
@@ -0,0 +1,382 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/subject"
+	"github.com/sundae-party/pki/types"
+	"github.com/sundae-party/pki/utils"
+)
+
+// addKeyFlags add the flags used to choose the algorithm and size of a new private key
+func addKeyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("keyAlgo", "rsa", "Private key algorithm: rsa, ecdsa or ed25519. (default is rsa)")
+	cmd.Flags().Int("keySize", 0, "Private key size, RSA modulus bits or ECDSA curve 256, 384 or 521. Ignored for ed25519. (default is 4096 for rsa and 256 for ecdsa)")
+}
+
+// keyOptionsFromFlags build the private key options from the flags added by addKeyFlags
+func keyOptionsFromFlags(cmd *cobra.Command) (types.KeyOptions, error) {
+	algoName, err := cmd.Flags().GetString("keyAlgo")
+	if err != nil {
+		return types.KeyOptions{}, err
+	}
+	algo, err := keys.ParseAlgorithm(algoName)
+	if err != nil {
+		return types.KeyOptions{}, err
+	}
+	size, err := cmd.Flags().GetInt("keySize")
+	if err != nil {
+		return types.KeyOptions{}, err
+	}
+	return types.KeyOptions{Algorithm: algo, Size: size}, nil
+}
+
+// addSKIMethodFlag add the flag used to choose how the subject key identifier is computed from the public key
+func addSKIMethodFlag(cmd *cobra.Command, defaultHelp string) {
+	cmd.Flags().String("skiMethod", "", "Subject key identifier method: sha1 (RFC 5280) or sha256 (RFC 7093). (default is "+defaultHelp+")")
+}
+
+// skiMethodFromFlags get the subject key identifier method of the flag added by addSKIMethodFlag
+func skiMethodFromFlags(cmd *cobra.Command) (keys.SKIMethod, error) {
+	name, err := cmd.Flags().GetString("skiMethod")
+	if err != nil {
+		return "", err
+	}
+	return keys.ParseSKIMethod(name)
+}
+
+// addInventoryFlags add the flags used to record an issued certificate in the CA inventory
+func addInventoryFlags(cmd *cobra.Command) {
+	cmd.Flags().String("inventory", "", "Inventory directory of the CA. (default is the CA cert path with the .inventory extension)")
+	cmd.Flags().String("requester", "", "Requester recorded in the CA inventory. (default is the current user)")
+}
+
+// inventoryFromFlags get the inventory directory and requester from the flags added by addInventoryFlags
+func inventoryFromFlags(cmd *cobra.Command) (string, string, error) {
+	inventoryPath, err := cmd.Flags().GetString("inventory")
+	if err != nil {
+		return "", "", err
+	}
+	requester, err := cmd.Flags().GetString("requester")
+	if err != nil {
+		return "", "", err
+	}
+	return inventoryPath, requester, nil
+}
+
+// passphraseSourceUsage describe the syntax of the passphrase source flags
+const passphraseSourceUsage = "pass:<passphrase>, env:<variable>, file:<path>, fd:<number> or prompt"
+
+// addCAKeyPassFlag add the flag used to get the passphrase of an encrypted CA private key
+func addCAKeyPassFlag(cmd *cobra.Command) {
+	cmd.Flags().String("caKeyPass", "", "Passphrase source of the CA private key when it's encrypted: "+passphraseSourceUsage+".")
+}
+
+// addKeyOutFlags add the flags used to encrypt a new private key file
+func addKeyOutFlags(cmd *cobra.Command) {
+	cmd.Flags().String("keyOutPass", "", "Passphrase source used to encrypt the new private key in PKCS#8: "+passphraseSourceUsage+". Written in clear if empty.")
+	cmd.Flags().String("keyKdf", "scrypt", "Key derivation function of the encrypted private key: scrypt or pbkdf2. (default is scrypt)")
+}
+
+// passphraseFromFlag read the passphrase from the source given in the flag name
+func passphraseFromFlag(cmd *cobra.Command, name string, prompt string, confirm bool) (string, error) {
+	source, err := cmd.Flags().GetString(name)
+	if err != nil {
+		return "", err
+	}
+	return utils.ReadPassphrase(source, prompt, confirm)
+}
+
+// keyEncryptionFromFlags build the private key encryption from the flags added by addKeyOutFlags
+func keyEncryptionFromFlags(cmd *cobra.Command) (keys.Encryption, error) {
+	kdfName, err := cmd.Flags().GetString("keyKdf")
+	if err != nil {
+		return keys.Encryption{}, err
+	}
+	kdf, err := keys.ParseKDF(kdfName)
+	if err != nil {
+		return keys.Encryption{}, err
+	}
+	pass, err := passphraseFromFlag(cmd, "keyOutPass", "Enter passphrase of the new private key", true)
+	if err != nil {
+		return keys.Encryption{}, err
+	}
+	return keys.Encryption{Passphrase: []byte(pass), KDF: kdf}, nil
+}
+
+// addSubjectFlags add the flags used to set the subject attributes of a new certificate, next to its common name flag
+func addSubjectFlags(cmd *cobra.Command) {
+	cmd.Flags().String("subject", "", "Subject distinguished name in RFC 4514 format, like \"CN=svc,O=team,OU=prod\". The other subject flags take precedence.")
+	cmd.Flags().StringSlice("org", []string{}, "Organization (O) of the subject.")
+	cmd.Flags().StringSlice("orgUnit", []string{}, "Organizational unit (OU) of the subject.")
+	cmd.Flags().StringSlice("country", []string{}, "Country code (C) of the subject.")
+	cmd.Flags().StringSlice("province", []string{}, "State or province (ST) of the subject.")
+	cmd.Flags().StringSlice("locality", []string{}, "Locality (L) of the subject.")
+	cmd.Flags().StringSlice("street", []string{}, "Street address (STREET) of the subject.")
+	cmd.Flags().StringSlice("postalCode", []string{}, "Postal code of the subject.")
+	cmd.Flags().String("serialNumber", "", "Serial number attribute of the subject, not the certificate serial.")
+	cmd.Flags().StringArray("subjectAttr", []string{}, "Other subject attribute as TYPE=value, the type being a keyword or a dotted OID, like 1.2.3.4=value.")
+}
+
+// subjectFromFlags build the subject from the flags added by addSubjectFlags and the common name flag cnFlag.
+// The attribute flags replace the same attributes of the --subject flag.
+func subjectFromFlags(cmd *cobra.Command, cnFlag string) (pkix.Name, error) {
+	name, err := optionalCNSubjectFromFlags(cmd, cnFlag)
+	if err != nil {
+		return pkix.Name{}, err
+	}
+	if name.CommonName == "" {
+		return pkix.Name{}, fmt.Errorf("a common name is required, set --%s or CN in --subject", cnFlag)
+	}
+	return name, nil
+}
+
+// optionalCNSubjectFromFlags build the subject like subjectFromFlags, the common name may be empty
+func optionalCNSubjectFromFlags(cmd *cobra.Command, cnFlag string) (pkix.Name, error) {
+	dn, err := cmd.Flags().GetString("subject")
+	if err != nil {
+		return pkix.Name{}, err
+	}
+	name, err := subject.Parse(dn)
+	if err != nil {
+		return pkix.Name{}, err
+	}
+
+	cn, err := cmd.Flags().GetString(cnFlag)
+	if err != nil {
+		return pkix.Name{}, err
+	}
+	if cmd.Flags().Changed(cnFlag) || name.CommonName == "" {
+		name.CommonName = cn
+	}
+
+	for flag, field := range map[string]*[]string{
+		"org":        &name.Organization,
+		"orgUnit":    &name.OrganizationalUnit,
+		"country":    &name.Country,
+		"province":   &name.Province,
+		"locality":   &name.Locality,
+		"street":     &name.StreetAddress,
+		"postalCode": &name.PostalCode,
+	} {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+		*field, err = cmd.Flags().GetStringSlice(flag)
+		if err != nil {
+			return pkix.Name{}, err
+		}
+	}
+	if cmd.Flags().Changed("serialNumber") {
+		name.SerialNumber, err = cmd.Flags().GetString("serialNumber")
+		if err != nil {
+			return pkix.Name{}, err
+		}
+	}
+
+	attrs, err := cmd.Flags().GetStringArray("subjectAttr")
+	if err != nil {
+		return pkix.Name{}, err
+	}
+	for _, attr := range attrs {
+		if err := subject.AddAttribute(&name, attr); err != nil {
+			return pkix.Name{}, err
+		}
+	}
+	return name, nil
+}
+
+// addURIEmailSansFlags add the flags used to set the URI and email SANs of a new certificate
+func addURIEmailSansFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("sansUri", []string{}, "Additional URIs in SANS, like spiffe://trust.domain/workload")
+	cmd.Flags().StringSlice("sansEmail", []string{}, "Additional email addresses in SANS")
+}
+
+// uriEmailSansFromFlags get the validated URI and email SANs from the flags added by addURIEmailSansFlags
+func uriEmailSansFromFlags(cmd *cobra.Command) ([]*url.URL, []string, error) {
+	rawURIs, err := cmd.Flags().GetStringSlice("sansUri")
+	if err != nil {
+		return nil, nil, err
+	}
+	uris, err := csr.ParseURIs(rawURIs)
+	if err != nil {
+		return nil, nil, err
+	}
+	emails, err := cmd.Flags().GetStringSlice("sansEmail")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, email := range emails {
+		if err := csr.ValidateEmail(email); err != nil {
+			return nil, nil, err
+		}
+	}
+	return uris, emails, nil
+}
+
+// addNameConstraintsFlags add the flags used to set the name constraints of a new CA
+func addNameConstraintsFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("permittedDns", []string{}, "DNS domains the CA can issue certificates for, with their subdomains. Only the subdomains if starting with a dot.")
+	cmd.Flags().StringSlice("excludedDns", []string{}, "DNS domains the CA can't issue certificates for, with their subdomains. Only the subdomains if starting with a dot.")
+	cmd.Flags().StringSlice("permittedIp", []string{}, "IP ranges in CIDR notation the CA can issue certificates for, like 10.0.0.0/8.")
+	cmd.Flags().StringSlice("excludedIp", []string{}, "IP ranges in CIDR notation the CA can't issue certificates for.")
+	cmd.Flags().StringSlice("permittedEmail", []string{}, "Mailboxes or email domains the CA can issue certificates for.")
+	cmd.Flags().StringSlice("excludedEmail", []string{}, "Mailboxes or email domains the CA can't issue certificates for.")
+	cmd.Flags().StringSlice("permittedUri", []string{}, "URI host domains the CA can issue certificates for.")
+	cmd.Flags().StringSlice("excludedUri", []string{}, "URI host domains the CA can't issue certificates for.")
+	cmd.Flags().Bool("nameConstraintsCritical", true, "Mark the name constraints extension critical, as required by RFC 5280. (default is true)")
+}
+
+// nameConstraintsFromFlags build the CA name constraints from the flags added by addNameConstraintsFlags
+func nameConstraintsFromFlags(cmd *cobra.Command) (ca.NameConstraints, error) {
+	var nc ca.NameConstraints
+	var err error
+	for flag, field := range map[string]*[]string{
+		"permittedDns":   &nc.PermittedDNSDomains,
+		"excludedDns":    &nc.ExcludedDNSDomains,
+		"permittedEmail": &nc.PermittedEmailAddresses,
+		"excludedEmail":  &nc.ExcludedEmailAddresses,
+		"permittedUri":   &nc.PermittedURIDomains,
+		"excludedUri":    &nc.ExcludedURIDomains,
+	} {
+		*field, err = cmd.Flags().GetStringSlice(flag)
+		if err != nil {
+			return ca.NameConstraints{}, err
+		}
+	}
+	for flag, field := range map[string]*[]*net.IPNet{
+		"permittedIp": &nc.PermittedIPRanges,
+		"excludedIp":  &nc.ExcludedIPRanges,
+	} {
+		cidrs, err := cmd.Flags().GetStringSlice(flag)
+		if err != nil {
+			return ca.NameConstraints{}, err
+		}
+		for _, cidr := range cidrs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return ca.NameConstraints{}, fmt.Errorf("invalid --%s range: %v", flag, err)
+			}
+			*field = append(*field, ipNet)
+		}
+	}
+	nc.Critical, err = cmd.Flags().GetBool("nameConstraintsCritical")
+	if err != nil {
+		return ca.NameConstraints{}, err
+	}
+	return nc, nil
+}
+
+// addProfileFlag add the flag used to select the issuance profile, a built-in profile or a profile of the config file
+func addProfileFlag(cmd *cobra.Command, defaultName string) {
+	usage := fmt.Sprintf("Issuance profile of the certificate, one of %v or a profile defined in the config file.", profile.Names())
+	if defaultName != "" {
+		usage += fmt.Sprintf(" (default is %s)", defaultName)
+	}
+	cmd.Flags().String("profile", defaultName, usage)
+}
+
+// profileFromFlags get the profile selected by the flag added by addProfileFlag, an empty profile if no profile is selected.
+// The profiles of the config file are registered first, so they can also be found by name later on.
+func profileFromFlags(cmd *cobra.Command) (profile.Profile, error) {
+	if err := registerConfigProfiles(); err != nil {
+		return profile.Profile{}, err
+	}
+	name, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return profile.Profile{}, err
+	}
+	if name == "" {
+		return profile.Profile{}, nil
+	}
+	return profile.Lookup(name)
+}
+
+var (
+	configProfilesOnce sync.Once
+	configProfilesErr  error
+)
+
+// registerConfigProfiles register the profiles defined under the profiles key of the config file.
+// The config file is checked once, the error is kept for the next calls.
+func registerConfigProfiles() error {
+	configProfilesOnce.Do(func() {
+		var configs map[string]profile.Config
+		if err := viper.UnmarshalKey("profiles", &configs); err != nil {
+			configProfilesErr = fmt.Errorf("invalid profiles in config file %s: %v", viper.ConfigFileUsed(), err)
+			return
+		}
+		if err := profile.RegisterConfigs(configs); err != nil {
+			configProfilesErr = fmt.Errorf("invalid config file %s: %v", viper.ConfigFileUsed(), err)
+		}
+	})
+	return configProfilesErr
+}
+
+// applyProfileDefaults replace the key options and duration by the ones of the profile, unless they are set with the flags
+func applyProfileDefaults(cmd *cobra.Command, p profile.Profile, keyOpts *types.KeyOptions, duration *time.Duration) {
+	if keyOpts != nil && p.Key != (types.KeyOptions{}) && !cmd.Flags().Changed("keyAlgo") && !cmd.Flags().Changed("keySize") {
+		*keyOpts = p.Key
+	}
+	if duration != nil && p.Duration != 0 && !cmd.Flags().Changed("exp") {
+		*duration = p.Duration
+	}
+}
+
+// addUsageFlags add the flags used to select the key usages and extended key usages of a new certificate
+func addUsageFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("keyUsage", []string{}, "Key usages replacing the profile ones: digitalSignature, contentCommitment, keyEncipherment, dataEncipherment, keyAgreement, keyCertSign, cRLSign, encipherOnly or decipherOnly.")
+	cmd.Flags().StringSlice("extKeyUsage", []string{}, "Extended key usages replacing the profile ones: serverAuth, clientAuth, codeSigning, emailProtection, OCSPSigning, timeStamping, ipsecEndSystem, ipsecTunnel, ipsecUser, any, or a custom dotted OID.")
+}
+
+// applyUsageFlags replace the usages of the profile by the ones of the flags added by addUsageFlags, when they are set
+func applyUsageFlags(cmd *cobra.Command, p *profile.Profile) error {
+	if cmd.Flags().Changed("keyUsage") {
+		names, err := cmd.Flags().GetStringSlice("keyUsage")
+		if err != nil {
+			return err
+		}
+		p.KeyUsage, err = profile.ParseKeyUsages(names)
+		if err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("extKeyUsage") {
+		names, err := cmd.Flags().GetStringSlice("extKeyUsage")
+		if err != nil {
+			return err
+		}
+		p.ExtKeyUsage, p.UnknownExtKeyUsage, err = profile.ParseExtKeyUsages(names)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/utils"
+)
+
+// importP12Cmd represents the import p12 command
+var importP12Cmd = &cobra.Command{
+	Use:   "p12",
+	Short: "Import a cert, key and CA chain from a PKCS#12 file",
+	Long:  `Unpack the cert, private key and CA chain of a password protected PKCS#12 (.p12/.pfx) file into pem files.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get PKCS#12 file and password from flags
+		inPath, err := cmd.Flags().GetString("in")
+		if err != nil {
+			return err
+		}
+		password, err := passphraseFromFlag(cmd, "pass", "Enter PKCS#12 password", false)
+		if err != nil {
+			return err
+		}
+
+		// Get destination folder and files name from flags
+		dest, err := cmd.Flags().GetString("dest")
+		if err != nil {
+			return err
+		}
+		certFileName, err := cmd.Flags().GetString("certFileName")
+		if err != nil {
+			return err
+		}
+		keyFileName, err := cmd.Flags().GetString("keyFileName")
+		if err != nil {
+			return err
+		}
+		chainFileName, err := cmd.Flags().GetString("chainFileName")
+		if err != nil {
+			return err
+		}
+
+		// Get key encryption from flags
+		keyEnc, err := keyEncryptionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		_, err = utils.ImportP12File(utils.P12ImportOptions{
+			InPath:        inPath,
+			Password:      password,
+			Dest:          dest,
+			CertFileName:  certFileName,
+			KeyFileName:   keyFileName,
+			ChainFileName: chainFileName,
+			KeyEncryption: keyEnc,
+		})
+		return err
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importP12Cmd)
+
+	// PKCS#12 file
+	importP12Cmd.Flags().StringP("in", "i", "", "PKCS#12 file path.")
+	importP12Cmd.MarkFlagRequired("in")
+	importP12Cmd.Flags().String("pass", "prompt", "Password source of the PKCS#12 file: "+passphraseSourceUsage+". (default is prompt)")
+
+	// Destination
+	importP12Cmd.Flags().StringP("dest", "d", "ssl", "Destination where the cert and key files will be created. (default is ./ssl)")
+
+	// Files name
+	importP12Cmd.Flags().String("certFileName", "cert.pem", "The cert file name. (default is cert.pem)")
+	importP12Cmd.Flags().String("keyFileName", "cert.key", "The key file name. (default is cert.key)")
+	importP12Cmd.Flags().String("chainFileName", "chain.pem", "The full chain file name, the cert followed by the CA chain. Not written if empty. (default is chain.pem)")
+
+	// Key encryption
+	addKeyOutFlags(importP12Cmd)
+}
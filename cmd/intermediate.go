@@ -0,0 +1,248 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/subject"
+	"github.com/sundae-party/pki/utils"
+)
+
+// intermediateCmd represents the intermediate command
+var intermediateCmd = &cobra.Command{
+	Use:   "intermediate",
+	Short: "Create new intermediate CA signed by a CA",
+	Long: `Create new intermediate CA signed by an existing root or intermediate CA.
+The intermediate CA cert, key and full chain (intermediate cert followed by the parent CA chain) files are created in the destination folder.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get parent CA info from flags
+		caKeyPath, err := cmd.Flags().GetString("caKey")
+		if err != nil {
+			return err
+		}
+		caCertPath, err := cmd.Flags().GetString("caCert")
+		if err != nil {
+			return err
+		}
+		caChainPath, err := cmd.Flags().GetString("caChain")
+		if err != nil {
+			return err
+		}
+		caKeyPass, err := passphraseFromFlag(cmd, "caKeyPass", "Enter passphrase of the CA private key", false)
+		if err != nil {
+			return err
+		}
+
+		// Load parent CA
+		parent, err := utils.LoadCAFromFile(caKeyPath, caKeyPass, caCertPath, caChainPath)
+		if err != nil {
+			return err
+		}
+
+		subj, err := subjectFromFlags(cmd, "cn")
+		if err != nil {
+			return err
+		}
+
+		// Set the cert validity
+		timeExp, err := cmd.Flags().GetInt("exp")
+		if err != nil {
+			return err
+		}
+		duration, err := time.ParseDuration(fmt.Sprintf("%dh", timeExp))
+		if err != nil {
+			return err
+		}
+
+		keyOpts, err := keyOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get the profile, its key, validity, usages and subject attributes are used unless set with the flags
+		p, err := profileFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		applyProfileDefaults(cmd, p, &keyOpts, &duration)
+		if err := applyUsageFlags(cmd, &p); err != nil {
+			return err
+		}
+
+		// Get the subject key identifier method
+		skiMethod, err := skiMethodFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		subj = subject.WithDefaults(subj, p.Subject)
+		keyEnc, err := keyEncryptionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		maxPathLen, err := cmd.Flags().GetInt("maxPathLen")
+		if err != nil {
+			return err
+		}
+
+		// Get CA name constraints
+		constraints, err := nameConstraintsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get SPIFFE trust domain pinned by the CA
+		trustDomain, err := cmd.Flags().GetString("spiffeTrustDomain")
+		if err != nil {
+			return err
+		}
+
+		// Get a serial number never issued by the parent CA
+		registryPath, err := cmd.Flags().GetString("serialRegistry")
+		if err != nil {
+			return err
+		}
+		if registryPath == "" {
+			registryPath = serial.RegistryPath(caCertPath)
+		}
+		registry, err := serial.OpenRegistry(registryPath)
+		if err != nil {
+			return err
+		}
+		sn, err := registry.Next()
+		if err != nil {
+			return err
+		}
+
+		// Gen new intermediate CA
+		intermediate, err := ca.CreateIntermediate(parent, ca.Options{
+			Subject:            subj,
+			Start:              time.Now(),
+			Duration:           duration,
+			Key:                keyOpts,
+			SerialNumber:       sn,
+			MaxPathLen:         maxPathLen,
+			MaxPathLenZero:     maxPathLen == 0,
+			KeyUsage:           p.KeyUsage,
+			ExtKeyUsage:        p.ExtKeyUsage,
+			UnknownExtKeyUsage: p.UnknownExtKeyUsage,
+			NameConstraints:    constraints,
+			SPIFFETrustDomain:  trustDomain,
+			SKIMethod:          skiMethod,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Create destination folder
+		dest, err := cmd.Flags().GetString("dest")
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			err := os.Mkdir(dest, 0700)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Build cert, key & chain dest path
+		certFileName, err := cmd.Flags().GetString("certName")
+		if err != nil {
+			return err
+		}
+		keyFileName, err := cmd.Flags().GetString("keyName")
+		if err != nil {
+			return err
+		}
+		chainFileName, err := cmd.Flags().GetString("chainName")
+		if err != nil {
+			return err
+		}
+		certPath := fmt.Sprintf("%s/%s", dest, certFileName)
+		keyPath := fmt.Sprintf("%s/%s", dest, keyFileName)
+		chainPath := fmt.Sprintf("%s/%s", dest, chainFileName)
+
+		// Write intermediate CA cert, key and chain files
+		err = ioutil.WriteFile(certPath, intermediate.CertPem.Bytes(), 0600)
+		if err != nil {
+			return err
+		}
+		keyPem, err := keyEnc.EncodePEM(intermediate.Key)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(keyPath, keyPem.Bytes(), 0600)
+		if err != nil {
+			return err
+		}
+		chainPem := utils.EncodeCertsPem(append([]*x509.Certificate{intermediate.Cert}, intermediate.Chain...))
+		err = ioutil.WriteFile(chainPath, chainPem.Bytes(), 0600)
+		if err != nil {
+			return err
+		}
+
+		// Record the intermediate CA in the parent CA inventory
+		inventoryPath, requester, err := inventoryFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		files := utils.CertFiles{CertPath: certPath, KeyPath: keyPath, ChainPath: chainPath}
+		return utils.RecordIssuance(inventoryPath, caCertPath, intermediate.Cert, "ca", requester, files)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(intermediateCmd)
+
+	// Parent CA used to sign the intermediate CA
+	intermediateCmd.Flags().String("caKey", "", "Parent CA Key path used to sign the intermediate CA.")
+	intermediateCmd.MarkFlagRequired("caKey")
+	addCAKeyPassFlag(intermediateCmd)
+	intermediateCmd.Flags().String("caCert", "", "Parent CA Cert path used to sign the intermediate CA.")
+	intermediateCmd.MarkFlagRequired("caCert")
+	intermediateCmd.Flags().String("caChain", "", "Issuer chain of the parent CA, when the parent is itself an intermediate CA.")
+	intermediateCmd.Flags().String("serialRegistry", "", "Serial registry file of the parent CA. (default is the parent CA cert path with the .serials extension)")
+	intermediateCmd.Flags().String("inventory", "", "Inventory directory of the parent CA. (default is the parent CA cert path with the .inventory extension)")
+	intermediateCmd.Flags().String("requester", "", "Requester recorded in the parent CA inventory. (default is the current user)")
+
+	intermediateCmd.Flags().String("cn", "", "Common Name to add in the intermediate CA. Required unless set in --subject.")
+	addSubjectFlags(intermediateCmd)
+
+	intermediateCmd.Flags().StringP("dest", "d", "ssl", "Destination where the intermediate CA files will be created. (default is ./ssl)")
+	intermediateCmd.Flags().String("certName", "intermediate.pem", "Intermediate CA cert file name. (default is intermediate.pem)")
+	intermediateCmd.Flags().String("keyName", "intermediate.key", "Intermediate CA key file name. (default is intermediate.key)")
+	intermediateCmd.Flags().String("chainName", "intermediate-chain.pem", "Intermediate CA full chain file name. (default is intermediate-chain.pem)")
+
+	intermediateCmd.Flags().Int("exp", 43800, "Time when the cert will expire from now. (default is 43800h - 5 years)")
+	addNameConstraintsFlags(intermediateCmd)
+	intermediateCmd.Flags().String("spiffeTrustDomain", "", "SPIFFE trust domain of the CA, its name constraints only permit SPIFFE IDs of this trust domain.")
+	intermediateCmd.Flags().Int("maxPathLen", 0, "Maximum number of intermediate CAs allowed below this CA, -1 for no limit. (default is 0)")
+	addKeyFlags(intermediateCmd)
+	addSKIMethodFlag(intermediateCmd, "sha1")
+	addKeyOutFlags(intermediateCmd)
+	addProfileFlag(intermediateCmd, "")
+	addUsageFlags(intermediateCmd)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/inventory"
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List certificates issued by a CA",
+	Long:  `List the certificates recorded in the inventory of a CA.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		inv, err := openInventoryFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Build the filter from flags
+		status, err := cmd.Flags().GetString("status")
+		if err != nil {
+			return err
+		}
+		subject, err := cmd.Flags().GetString("subject")
+		if err != nil {
+			return err
+		}
+		profileName, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			return err
+		}
+		expiringWithin, err := cmd.Flags().GetInt("expiringWithin")
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		filter := inventory.Filter{
+			Status:  inventory.Status(status),
+			Subject: subject,
+			Profile: profileName,
+			Now:     now,
+		}
+		if expiringWithin > 0 {
+			filter.ExpiresBefore = now.Add(time.Duration(expiringWithin) * time.Hour)
+		}
+
+		records, err := inv.List(filter)
+		if err != nil {
+			return err
+		}
+
+		asJson, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return err
+		}
+		if asJson {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(records)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SERIAL\tSTATUS\tNOT AFTER\tPROFILE\tSUBJECT")
+		for _, r := range records {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Serial, r.StatusAt(now), r.NotAfter.Format(time.RFC3339), r.Profile, r.Subject)
+		}
+		return w.Flush()
+	},
+}
+
+// openInventoryFromFlags open the inventory given by the inventory flag, or next to the caCert flag
+func openInventoryFromFlags(cmd *cobra.Command) (*inventory.Inventory, error) {
+	caCertPath, err := cmd.Flags().GetString("caCert")
+	if err != nil {
+		return nil, err
+	}
+	inventoryPath, err := cmd.Flags().GetString("inventory")
+	if err != nil {
+		return nil, err
+	}
+	if inventoryPath == "" {
+		if caCertPath == "" {
+			return nil, fmt.Errorf("one of --caCert or --inventory is required")
+		}
+		inventoryPath = inventory.Path(caCertPath)
+	}
+	if _, err := os.Stat(inventoryPath); err != nil {
+		return nil, err
+	}
+	return inventory.Open(inventoryPath)
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().String("caCert", "", "CA Cert path, used to find the inventory next to it.")
+	listCmd.Flags().String("inventory", "", "Inventory directory of the CA. (default is the CA cert path with the .inventory extension)")
+
+	// Filters
+	listCmd.Flags().String("status", "", "Only list the certificates with this status: valid, revoked or expired.")
+	listCmd.Flags().String("subject", "", "Only list the certificates with a subject containing this value.")
+	listCmd.Flags().String("profile", "", "Only list the certificates issued with this profile.")
+	listCmd.Flags().Int("expiringWithin", 0, "Only list the certificates expiring within this number of hours.")
+
+	listCmd.Flags().Bool("json", false, "Print the certificates in json format.")
+}
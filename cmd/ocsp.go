@@ -0,0 +1,31 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// ocspCmd represents the ocsp command
+var ocspCmd = &cobra.Command{
+	Use:   "ocsp",
+	Short: "OCSP responder",
+	Long:  `Serve the revocation status of the certificates issued by a CA with the OCSP protocol (RFC 6960).`,
+}
+
+func init() {
+	rootCmd.AddCommand(ocspCmd)
+}
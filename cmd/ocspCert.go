@@ -0,0 +1,169 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/utils"
+)
+
+// ocspCertCmd represents the ocsp cert command
+var ocspCertCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Create new delegated OCSP responder cert and key",
+	Long:  `Create a new certificate and key signed by a CA with the OCSP signing usage, to sign OCSP responses on behalf of the CA.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get CA info from flags
+		caKeyPath, err := cmd.Flags().GetString("caKey")
+		if err != nil {
+			return err
+		}
+		caCertPath, err := cmd.Flags().GetString("caCert")
+		if err != nil {
+			return err
+		}
+		serialRegistry, err := cmd.Flags().GetString("serialRegistry")
+		if err != nil {
+			return err
+		}
+
+		// Get the subject from flags
+		subj, err := subjectFromFlags(cmd, "certCn")
+		if err != nil {
+			return err
+		}
+
+		// Build the cert validity from flags
+		durationString, err := cmd.Flags().GetInt("exp")
+		if err != nil {
+			return err
+		}
+		duration, err := time.ParseDuration(fmt.Sprintf("%dh", durationString))
+		if err != nil {
+			return err
+		}
+
+		// Get destination folder and files name from flags
+		dest, err := cmd.Flags().GetString("dest")
+		if err != nil {
+			return err
+		}
+		certFileName, err := cmd.Flags().GetString("certFileName")
+		if err != nil {
+			return err
+		}
+		keyFileName, err := cmd.Flags().GetString("keyFileName")
+		if err != nil {
+			return err
+		}
+
+		keyOpts, err := keyOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get the issuance profile, its key and validity are used unless set with the flags
+		p, err := profileFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		applyProfileDefaults(cmd, p, &keyOpts, &duration)
+		if err := applyUsageFlags(cmd, &p); err != nil {
+			return err
+		}
+
+		// Get the subject key identifier method
+		skiMethod, err := skiMethodFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get CA inventory and requester from flags
+		inventoryPath, requester, err := inventoryFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get CA key passphrase and new key encryption from flags
+		caKeyPass, err := passphraseFromFlag(cmd, "caKeyPass", "Enter passphrase of the CA private key", false)
+		if err != nil {
+			return err
+		}
+		keyEnc, err := keyEncryptionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		return utils.CreateCert(utils.CertOptions{
+			CAKeyPath:       caKeyPath,
+			CACertPath:      caCertPath,
+			Subject:         subj,
+			Duration:        duration,
+			Dest:            dest,
+			CertFileName:    certFileName,
+			KeyFileName:     keyFileName,
+			Key:             keyOpts,
+			SerialRegistry:  serialRegistry,
+			Profile:         p,
+			Inventory:       inventoryPath,
+			Requester:       requester,
+			CAKeyPassphrase: caKeyPass,
+			KeyEncryption:   keyEnc,
+			SKIMethod:       skiMethod,
+		})
+	},
+}
+
+func init() {
+	ocspCmd.AddCommand(ocspCertCmd)
+
+	// CA used to sign the responder cert
+	ocspCertCmd.Flags().String("caKey", "", "CA Key path used to sign the responder certificate.")
+	ocspCertCmd.MarkFlagRequired("caKey")
+	addCAKeyPassFlag(ocspCertCmd)
+	ocspCertCmd.Flags().String("caCert", "", "CA Cert path used to sign the responder certificate.")
+	ocspCertCmd.MarkFlagRequired("caCert")
+	ocspCertCmd.Flags().String("serialRegistry", "", "Serial registry file of the CA. (default is the CA cert path with the .serials extension)")
+	addInventoryFlags(ocspCertCmd)
+
+	// CN
+	ocspCertCmd.Flags().String("certCn", "OCSP responder", "Common Name to add in the responder cert. (default is OCSP responder)")
+	addSubjectFlags(ocspCertCmd)
+
+	// Destination
+	ocspCertCmd.Flags().StringP("dest", "d", "ssl", "Destination where the cert and key files will be created. (default is ./ssl)")
+
+	// Files name
+	ocspCertCmd.Flags().String("certFileName", "ocsp.pem", "The cert file name. (default is ocsp.pem)")
+	ocspCertCmd.Flags().String("keyFileName", "ocsp.key", "The key file name. (default is ocsp.key)")
+
+	// Duration, responder certs are not checked for revocation so keep them short lived
+	ocspCertCmd.Flags().Int("exp", 2160, "Time when the cert will expire from now. (default is 2160h - 90 days)")
+
+	addKeyFlags(ocspCertCmd)
+	addSKIMethodFlag(ocspCertCmd, "sha1")
+	addKeyOutFlags(ocspCertCmd)
+
+	// Profile
+	addProfileFlag(ocspCertCmd, profile.Ocsp.Name)
+	addUsageFlags(ocspCertCmd)
+}
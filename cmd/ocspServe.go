@@ -0,0 +1,154 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/crl"
+	"github.com/sundae-party/pki/responder"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/types"
+	"github.com/sundae-party/pki/utils"
+)
+
+// ocspServeCmd represents the ocsp serve command
+var ocspServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start an OCSP responder for a CA",
+	Long: `Start an HTTP OCSP responder answering for the certificates issued by a CA, backed by the CA revocation list and serial registry.
+Responses are signed with the CA key, or with a delegated responder cert created by the ocsp cert command.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get CA info from flags
+		caCertPath, err := cmd.Flags().GetString("caCert")
+		if err != nil {
+			return err
+		}
+		caKeyPath, err := cmd.Flags().GetString("caKey")
+		if err != nil {
+			return err
+		}
+		listPath, err := cmd.Flags().GetString("revocationList")
+		if err != nil {
+			return err
+		}
+		if listPath == "" {
+			listPath = crl.ListPath(caCertPath)
+		}
+		registryPath, err := cmd.Flags().GetString("serialRegistry")
+		if err != nil {
+			return err
+		}
+		if registryPath == "" {
+			registryPath = serial.RegistryPath(caCertPath)
+		}
+
+		// Get delegated responder info from flags
+		responderCertPath, err := cmd.Flags().GetString("responderCert")
+		if err != nil {
+			return err
+		}
+		responderKeyPath, err := cmd.Flags().GetString("responderKey")
+		if err != nil {
+			return err
+		}
+
+		// Load the responses signer, the CA itself or the delegated responder
+		var signer *types.Cert
+		switch {
+		case (responderCertPath != "") != (responderKeyPath != ""):
+			return errors.New("--responderCert and --responderKey must be used together")
+		case responderCertPath != "":
+			var pass string
+			pass, err = passphraseFromFlag(cmd, "responderKeyPass", "Enter passphrase of the responder private key", false)
+			if err == nil {
+				signer, err = utils.LoadCertFromFile(responderKeyPath, pass, responderCertPath)
+			}
+		case caKeyPath != "":
+			var pass string
+			pass, err = passphraseFromFlag(cmd, "caKeyPass", "Enter passphrase of the CA private key", false)
+			if err == nil {
+				signer, err = utils.LoadCertFromFile(caKeyPath, pass, caCertPath)
+			}
+		default:
+			return fmt.Errorf("one of --caKey or --responderCert and --responderKey is required")
+		}
+		if err != nil {
+			return err
+		}
+		caCerts, err := utils.LoadChainFromFile(caCertPath)
+		if err != nil {
+			return err
+		}
+
+		// Get response validity
+		nextUpdate, err := cmd.Flags().GetInt("nextUpdate")
+		if err != nil {
+			return err
+		}
+		validity, err := time.ParseDuration(fmt.Sprintf("%dm", nextUpdate))
+		if err != nil {
+			return err
+		}
+
+		r, err := responder.New(caCerts[0], signer, listPath, registryPath, validity)
+		if err != nil {
+			return err
+		}
+		r.ErrorLog = log.New(os.Stderr, "", log.LstdFlags)
+
+		addr, err := cmd.Flags().GetString("addr")
+		if err != nil {
+			return err
+		}
+		server := &http.Server{
+			Addr:         addr,
+			Handler:      r,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+		log.Printf("OCSP responder for %s listening on %s", caCerts[0].Subject, addr)
+		return server.ListenAndServe()
+	},
+}
+
+func init() {
+	ocspCmd.AddCommand(ocspServeCmd)
+
+	// CA
+	ocspServeCmd.Flags().String("caCert", "", "CA Cert path which issued the certificates.")
+	ocspServeCmd.MarkFlagRequired("caCert")
+	ocspServeCmd.Flags().String("caKey", "", "CA Key path used to sign the responses when no delegated responder is given.")
+	addCAKeyPassFlag(ocspServeCmd)
+	ocspServeCmd.Flags().String("revocationList", "", "Revocation list file of the CA. (default is the CA cert path with the .revoked extension)")
+	ocspServeCmd.Flags().String("serialRegistry", "", "Serial registry file of the CA. (default is the CA cert path with the .serials extension)")
+
+	// Delegated responder
+	ocspServeCmd.Flags().String("responderCert", "", "Delegated responder Cert path used to sign the responses.")
+	ocspServeCmd.Flags().String("responderKey", "", "Delegated responder Key path used to sign the responses.")
+	ocspServeCmd.Flags().String("responderKeyPass", "", "Passphrase source of the delegated responder private key when it's encrypted: "+passphraseSourceUsage+".")
+
+	ocspServeCmd.Flags().String("addr", ":8080", "Address the responder listens on. (default is :8080)")
+	ocspServeCmd.Flags().Int("nextUpdate", 60, "Time in minutes until the next update set in the responses. (default is 60 minutes)")
+}
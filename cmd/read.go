@@ -16,10 +16,20 @@ limitations under the License.
 package cmd
 
 import (
-	"log"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 
+	"github.com/sundae-party/pki/keys"
 	"github.com/sundae-party/pki/utils"
 )
 
@@ -27,50 +37,156 @@ import (
 var readCmd = &cobra.Command{
 	Use:   "read",
 	Short: "Show info about a cert",
-	Long:  ``,
+	Long: `Show the details of a certificate in pem or der format, read from a file or from stdin with --cert -.
+With --chain all the certificates of the file are shown, the first one only otherwise.
+When the private key is given, it is checked against the certificate public key.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		// Build cert path and key path
+		// Get output format from flags
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		if output != "text" && output != "json" && output != "yaml" {
+			return fmt.Errorf("unsupported output %q, expected text, json or yaml", output)
+		}
+
+		// Read the certs from the cert file or stdin
 		certFilePath, err := cmd.Flags().GetString("cert")
 		if err != nil {
 			return err
 		}
-		keyFilePath, err := cmd.Flags().GetString("key")
+		var certBytes []byte
+		if certFilePath == "-" {
+			certBytes, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			certBytes, err = ioutil.ReadFile(certFilePath)
+		}
 		if err != nil {
 			return err
 		}
-		cert, err := utils.LoadCertFromFile(keyFilePath, "", certFilePath)
+		certs, err := utils.ParseChain(certBytes)
+		if err != nil {
+			return fmt.Errorf("%s: %v", certFilePath, err)
+		}
+		chain, err := cmd.Flags().GetBool("chain")
 		if err != nil {
 			return err
 		}
+		if !chain {
+			certs = certs[:1]
+		}
 
-		log.Printf("DNS Names : %s \n", cert.Cert.DNSNames)
-		log.Printf("Ip : %s", cert.Cert.IPAddresses)
-		log.Printf("Is CA : %t \n", cert.Cert.IsCA)
-		log.Printf("Not Before : %s", cert.Cert.NotBefore)
-		log.Printf("NotAfter : %s", cert.Cert.NotAfter)
-		log.Printf("Subject : %s", cert.Cert.Subject)
+		var infos []utils.CertInfo
+		for _, cert := range certs {
+			infos = append(infos, utils.DescribeCert(cert))
+		}
 
+		// Check the private key against the first cert
+		keyFilePath, err := cmd.Flags().GetString("key")
+		if err != nil {
+			return err
+		}
+		if keyFilePath != "" {
+			keyPass, err := passphraseFromFlag(cmd, "keyPass", "Enter passphrase of the private key", false)
+			if err != nil {
+				return err
+			}
+			key, err := utils.LoadKeyFromFile(keyFilePath, keyPass)
+			if err != nil {
+				return err
+			}
+			match := keys.CheckMatch(key, certs[0].PublicKey) == nil
+			infos[0].KeyMatch = &match
+		}
+
+		switch output {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if chain {
+				return enc.Encode(infos)
+			}
+			return enc.Encode(infos[0])
+		case "yaml":
+			enc := yaml.NewEncoder(os.Stdout)
+			defer enc.Close()
+			if chain {
+				return enc.Encode(infos)
+			}
+			return enc.Encode(infos[0])
+		}
+
+		for i, info := range infos {
+			if chain {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("Certificate #%d\n", i+1)
+			}
+			if err := printCertInfo(os.Stdout, info); err != nil {
+				return err
+			}
+		}
 		return nil
 	},
 }
 
+// printCertInfo print the cert details in text format
+func printCertInfo(out io.Writer, info utils.CertInfo) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Serial:\t%s\n", info.Serial)
+	fmt.Fprintf(w, "Version:\t%d\n", info.Version)
+	fmt.Fprintf(w, "Signature Algorithm:\t%s\n", info.SignatureAlgorithm)
+	fmt.Fprintf(w, "Issuer:\t%s\n", info.Issuer)
+	fmt.Fprintf(w, "Subject:\t%s\n", info.Subject)
+	fmt.Fprintf(w, "Not Before:\t%s\n", info.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(w, "Not After:\t%s\n", info.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(w, "DNS Names:\t%s\n", strings.Join(info.DNSNames, ", "))
+	fmt.Fprintf(w, "IP Addresses:\t%s\n", strings.Join(info.IPAddresses, ", "))
+	fmt.Fprintf(w, "URIs:\t%s\n", strings.Join(info.URIs, ", "))
+	fmt.Fprintf(w, "Emails:\t%s\n", strings.Join(info.EmailAddresses, ", "))
+	fmt.Fprintf(w, "Key Algorithm:\t%s\n", info.KeyAlgorithm)
+	fmt.Fprintf(w, "Key Usage:\t%s\n", strings.Join(info.KeyUsage, ", "))
+	fmt.Fprintf(w, "Extended Key Usage:\t%s\n", strings.Join(info.ExtKeyUsage, ", "))
+	fmt.Fprintf(w, "Is CA:\t%t\n", info.IsCA)
+	if info.MaxPathLen != nil {
+		maxPathLen := "unlimited"
+		if *info.MaxPathLen >= 0 {
+			maxPathLen = strconv.Itoa(*info.MaxPathLen)
+		}
+		fmt.Fprintf(w, "Max Path Length:\t%s\n", maxPathLen)
+	}
+	skiMethod := ""
+	switch keys.SKIMethod(info.SubjectKeyIDMethod) {
+	case keys.SKISHA1:
+		skiMethod = " (sha1, RFC 5280)"
+	case keys.SKISHA256:
+		skiMethod = " (sha256, RFC 7093)"
+	}
+	fmt.Fprintf(w, "Subject Key Id:\t%s%s\n", info.SubjectKeyID, skiMethod)
+	fmt.Fprintf(w, "Authority Key Id:\t%s\n", info.AuthorityKeyID)
+	fmt.Fprintf(w, "CRL Distribution Points:\t%s\n", strings.Join(info.CRLDistributionPoints, ", "))
+	fmt.Fprintf(w, "OCSP Servers:\t%s\n", strings.Join(info.OCSPServers, ", "))
+	fmt.Fprintf(w, "Issuing Certificate URL:\t%s\n", strings.Join(info.IssuingCertificateURL, ", "))
+	fmt.Fprintf(w, "SHA-1 Fingerprint:\t%s\n", info.FingerprintSHA1)
+	fmt.Fprintf(w, "SHA-256 Fingerprint:\t%s\n", info.FingerprintSHA256)
+	fmt.Fprintf(w, "SPKI Pin (SHA-256):\t%s\n", info.SPKIPin)
+	if info.KeyMatch != nil {
+		fmt.Fprintf(w, "Private Key Match:\t%t\n", *info.KeyMatch)
+	}
+	return w.Flush()
+}
+
 func init() {
 	rootCmd.AddCommand(readCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// readCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// readCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	readCmd.Flags().StringP("cert", "c", "", "Cert path, - to read from stdin.")
+	readCmd.MarkFlagRequired("cert")
+	readCmd.Flags().Bool("chain", false, "Show all the certificates of the cert file, not only the first one.")
 
-	readCmd.Flags().StringP("key", "k", "", "Key path.")
-	readCmd.MarkFlagRequired("key")
+	readCmd.Flags().StringP("key", "k", "", "Optional key path, checked against the cert public key.")
+	readCmd.Flags().String("keyPass", "", "Passphrase source of the private key when it's encrypted: "+passphraseSourceUsage+".")
 
-	readCmd.Flags().StringP("cert", "c", "", "Cert path.")
-	readCmd.MarkFlagRequired("cert")
+	readCmd.Flags().StringP("output", "o", "text", "Output format: text, json or yaml. (default is text)")
 }
@@ -0,0 +1,186 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/utils"
+)
+
+// renewCmd represents the renew command
+var renewCmd = &cobra.Command{
+	Use:   "renew",
+	Short: "Renew a certificate",
+	Long: `Re-issue an existing certificate from the same CA with the same subject, SANs, key usages and profile.
+The private key is reused unless --rotateKey is set. The new files replace the previous ones atomically,
+the previous files are kept with the .bak extension.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get CA info from flags
+		caKeyPath, err := cmd.Flags().GetString("caKey")
+		if err != nil {
+			return err
+		}
+		caCertPath, err := cmd.Flags().GetString("caCert")
+		if err != nil {
+			return err
+		}
+		caChainPath, err := cmd.Flags().GetString("caChain")
+		if err != nil {
+			return err
+		}
+		serialRegistry, err := cmd.Flags().GetString("serialRegistry")
+		if err != nil {
+			return err
+		}
+		inventoryPath, requester, err := inventoryFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get the files of the certificate to renew
+		certPath, err := cmd.Flags().GetString("cert")
+		if err != nil {
+			return err
+		}
+		keyPath, err := cmd.Flags().GetString("key")
+		if err != nil {
+			return err
+		}
+		chainPath, err := cmd.Flags().GetString("chain")
+		if err != nil {
+			return err
+		}
+
+		// Get the key rotation options
+		rotateKey, err := cmd.Flags().GetBool("rotateKey")
+		if err != nil {
+			return err
+		}
+		keyOpts, err := keyOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("keyAlgo") {
+			// Keep the algorithm of the old key, and its size unless a new size is set
+			keyOpts.Algorithm = ""
+			if !cmd.Flags().Changed("keySize") {
+				keyOpts.Size = 0
+			}
+		}
+
+		// Get the passphrases of the CA key and of the reused key, and the rotated key encryption
+		caKeyPass, err := passphraseFromFlag(cmd, "caKeyPass", "Enter passphrase of the CA private key", false)
+		if err != nil {
+			return err
+		}
+		var keyPass string
+		if !rotateKey {
+			keyPass, err = passphraseFromFlag(cmd, "keyPass", "Enter passphrase of the private key", false)
+			if err != nil {
+				return err
+			}
+		}
+		keyEnc, err := keyEncryptionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get the validity from flags, the old validity period is kept if not set
+		var duration time.Duration
+		if cmd.Flags().Changed("exp") {
+			durationString, err := cmd.Flags().GetInt("exp")
+			if err != nil {
+				return err
+			}
+			duration, err = time.ParseDuration(fmt.Sprintf("%dh", durationString))
+			if err != nil {
+				return err
+			}
+		}
+
+		// Get the profile replacing the recorded one, its key and validity are used unless set with the flags
+		p, err := profileFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		applyProfileDefaults(cmd, p, &keyOpts, &duration)
+
+		// Get the subject key identifier method
+		skiMethod, err := skiMethodFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		return utils.RenewCertFile(utils.RenewOptions{
+			CAKeyPath:       caKeyPath,
+			CACertPath:      caCertPath,
+			CertPath:        certPath,
+			KeyPath:         keyPath,
+			ChainPath:       chainPath,
+			Duration:        duration,
+			RotateKey:       rotateKey,
+			Key:             keyOpts,
+			SerialRegistry:  serialRegistry,
+			CAChainPath:     caChainPath,
+			Inventory:       inventoryPath,
+			Requester:       requester,
+			CAKeyPassphrase: caKeyPass,
+			KeyPassphrase:   keyPass,
+			Profile:         p,
+			KeyEncryption:   keyEnc,
+			SKIMethod:       skiMethod,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renewCmd)
+
+	// Certificate to renew
+	renewCmd.Flags().String("cert", "", "Cert path of the certificate to renew, replaced by the new certificate.")
+	renewCmd.MarkFlagRequired("cert")
+	renewCmd.Flags().String("key", "", "Key path of the certificate to renew, replaced by the new key when rotated.")
+	renewCmd.MarkFlagRequired("key")
+	renewCmd.Flags().String("keyPass", "", "Passphrase source of the reused private key when it's encrypted: "+passphraseSourceUsage+".")
+	renewCmd.Flags().String("chain", "", "Full chain path of the certificate to renew, replaced by the new chain. (default is the chain recorded in the CA inventory)")
+
+	// CA used to sign the new certificate
+	renewCmd.Flags().String("caKey", "", "CA Key path used to sign the new certificate.")
+	renewCmd.MarkFlagRequired("caKey")
+	addCAKeyPassFlag(renewCmd)
+	renewCmd.Flags().String("caCert", "", "CA Cert path used to sign the new certificate, must be the CA which issued the certificate to renew.")
+	renewCmd.MarkFlagRequired("caCert")
+	renewCmd.Flags().String("caChain", "", "Issuer chain of the CA used to sign the new certificate, when the CA is an intermediate.")
+	renewCmd.Flags().String("serialRegistry", "", "Serial registry file of the CA. (default is the CA cert path with the .serials extension)")
+	addInventoryFlags(renewCmd)
+
+	// Key rotation
+	renewCmd.Flags().Bool("rotateKey", false, "Generate a new private key instead of reusing the current one.")
+	addKeyFlags(renewCmd)
+	addSKIMethodFlag(renewCmd, "the method of the old certificate")
+	addKeyOutFlags(renewCmd)
+
+	// Profile replacing the profile recorded in the CA inventory
+	addProfileFlag(renewCmd, "")
+
+	// Duration
+	renewCmd.Flags().Int("exp", 0, "Time when the new cert will expire from now. (default is the validity period of the current cert)")
+}
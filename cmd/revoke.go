@@ -0,0 +1,188 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/crl"
+	"github.com/sundae-party/pki/inventory"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/utils"
+)
+
+// revokeCmd represents the revoke command
+var revokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke a certificate issued by a CA",
+	Long: `Add a certificate to the revocation list of the CA that issued it.
+A certificate revoked with the certificateHold reason can be released with --unhold.
+Run the crl command to publish a new CRL with the revocation.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get CA info from flags
+		caCertPath, err := cmd.Flags().GetString("caCert")
+		if err != nil {
+			return err
+		}
+		listPath, err := cmd.Flags().GetString("revocationList")
+		if err != nil {
+			return err
+		}
+		if listPath == "" {
+			listPath = crl.ListPath(caCertPath)
+		}
+
+		// Get the serial to revoke from the serial or cert flag
+		serialHex, err := cmd.Flags().GetString("serial")
+		if err != nil {
+			return err
+		}
+		certPath, err := cmd.Flags().GetString("cert")
+		if err != nil {
+			return err
+		}
+		var sn *big.Int
+		switch {
+		case serialHex != "" && certPath != "":
+			return errors.New("only one of --serial or --cert can be used")
+		case serialHex != "":
+			sn, err = serial.Parse(serialHex)
+			if err != nil {
+				return err
+			}
+			if err := checkIssuedSerial(cmd, caCertPath, sn); err != nil {
+				return err
+			}
+		case certPath != "":
+			certs, err := utils.LoadChainFromFile(certPath)
+			if err != nil {
+				return err
+			}
+			caCerts, err := utils.LoadChainFromFile(caCertPath)
+			if err != nil {
+				return err
+			}
+			if err := certs[0].CheckSignatureFrom(caCerts[0]); err != nil {
+				return fmt.Errorf("%s is not issued by %s: %v", certPath, caCertPath, err)
+			}
+			sn = certs[0].SerialNumber
+		default:
+			return errors.New("one of --serial or --cert is required")
+		}
+
+		// Revoke or release the certificate
+		unhold, err := cmd.Flags().GetBool("unhold")
+		if err != nil {
+			return err
+		}
+		if unhold {
+			err = crl.UpdateList(listPath, func(list *crl.List) error {
+				return list.Unhold(sn)
+			})
+			if err != nil {
+				return err
+			}
+			return setInventoryStatus(cmd, caCertPath, sn, inventory.StatusValid)
+		}
+
+		reasonName, err := cmd.Flags().GetString("reason")
+		if err != nil {
+			return err
+		}
+		reason, err := crl.ParseReason(reasonName)
+		if err != nil {
+			return err
+		}
+		err = crl.UpdateList(listPath, func(list *crl.List) error {
+			return list.Revoke(sn, reason, time.Now())
+		})
+		if err != nil {
+			return err
+		}
+		return setInventoryStatus(cmd, caCertPath, sn, inventory.StatusRevoked)
+	},
+}
+
+// checkIssuedSerial check the serial number is recorded in the CA serial registry, unless force is set
+func checkIssuedSerial(cmd *cobra.Command, caCertPath string, sn *big.Int) error {
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	if force {
+		return nil
+	}
+	registryPath, err := cmd.Flags().GetString("serialRegistry")
+	if err != nil {
+		return err
+	}
+	if registryPath == "" {
+		registryPath = serial.RegistryPath(caCertPath)
+	}
+	registry, err := serial.OpenRegistry(registryPath)
+	if err != nil {
+		return err
+	}
+	if !registry.Contains(sn) {
+		return fmt.Errorf("serial %s is not in the serial registry %s of the CA, use --force to revoke it anyway", serial.Format(sn), registryPath)
+	}
+	return nil
+}
+
+// setInventoryStatus update the status of the certificate in the CA inventory, if the certificate was recorded
+func setInventoryStatus(cmd *cobra.Command, caCertPath string, sn *big.Int, status inventory.Status) error {
+	inventoryPath, err := cmd.Flags().GetString("inventory")
+	if err != nil {
+		return err
+	}
+	if inventoryPath == "" {
+		inventoryPath = inventory.Path(caCertPath)
+	}
+	inv, err := inventory.Open(inventoryPath)
+	if err != nil {
+		return err
+	}
+	err = inv.SetStatus(sn, status)
+	if errors.Is(err, inventory.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(revokeCmd)
+
+	// CA which issued the certificate
+	revokeCmd.Flags().String("caCert", "", "CA Cert path which issued the certificate to revoke.")
+	revokeCmd.MarkFlagRequired("caCert")
+	revokeCmd.Flags().String("revocationList", "", "Revocation list file of the CA. (default is the CA cert path with the .revoked extension)")
+	revokeCmd.Flags().String("serialRegistry", "", "Serial registry file of the CA, checked for the serial numbers given with --serial. (default is the CA cert path with the .serials extension)")
+	revokeCmd.Flags().String("inventory", "", "Inventory directory of the CA. (default is the CA cert path with the .inventory extension)")
+
+	// Certificate to revoke
+	revokeCmd.Flags().String("serial", "", "Hexadecimal serial number of the certificate to revoke.")
+	revokeCmd.Flags().String("cert", "", "Cert path of the certificate to revoke.")
+	revokeCmd.Flags().Bool("force", false, "Revoke a serial number given with --serial even if it's not in the serial registry of the CA.")
+
+	revokeCmd.Flags().String("reason", crl.Unspecified.String(), "Revocation reason: unspecified, keyCompromise, cACompromise, affiliationChanged, superseded, cessationOfOperation, certificateHold, privilegeWithdrawn or aACompromise. (default is unspecified)")
+	revokeCmd.Flags().Bool("unhold", false, "Release a certificate revoked with the certificateHold reason.")
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -39,8 +40,30 @@ var rootCmd = &cobra.Command{
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// A command returning an exitError exits with its code, without printing an error.
 func Execute() {
-	cobra.CheckErr(rootCmd.Execute())
+	err := rootCmd.Execute()
+	var exitErr exitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
+	cobra.CheckErr(err)
+}
+
+// exitError result of a command which already reported its outcome and only sets the process exit code
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// exitWithCode get the error returned by cmd to exit with code, the error and the usage are not printed
+func exitWithCode(cmd *cobra.Command, code int) error {
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return exitError{code: code}
 }
 
 func init() {
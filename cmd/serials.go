@@ -0,0 +1,84 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/serial"
+)
+
+// serialsCmd represents the serials command
+var serialsCmd = &cobra.Command{
+	Use:   "serials",
+	Short: "List serial numbers issued by a CA",
+	Long:  `List the serial numbers recorded in the serial registry of a CA, or check if a serial number is already issued.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get registry path from flags
+		caCertPath, err := cmd.Flags().GetString("caCert")
+		if err != nil {
+			return err
+		}
+		registryPath, err := cmd.Flags().GetString("serialRegistry")
+		if err != nil {
+			return err
+		}
+		if registryPath == "" {
+			if caCertPath == "" {
+				return fmt.Errorf("one of --caCert or --serialRegistry is required")
+			}
+			registryPath = serial.RegistryPath(caCertPath)
+		}
+
+		registry, err := serial.OpenRegistry(registryPath)
+		if err != nil {
+			return err
+		}
+
+		// Check a single serial
+		check, err := cmd.Flags().GetString("check")
+		if err != nil {
+			return err
+		}
+		if check != "" {
+			sn, err := serial.Parse(check)
+			if err != nil {
+				return err
+			}
+			if !registry.Contains(sn) {
+				return fmt.Errorf("serial %s not issued", serial.Format(sn))
+			}
+			fmt.Printf("serial %s issued\n", serial.Format(sn))
+			return nil
+		}
+
+		for _, sn := range registry.Serials() {
+			fmt.Println(serial.Format(sn))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serialsCmd)
+
+	serialsCmd.Flags().String("caCert", "", "CA Cert path, used to find the serial registry next to it.")
+	serialsCmd.Flags().String("serialRegistry", "", "Serial registry file. (default is the CA cert path with the .serials extension)")
+	serialsCmd.Flags().String("check", "", "Hexadecimal serial number to look up in the registry.")
+}
@@ -17,11 +17,11 @@ package cmd
 
 import (
 	"fmt"
-	"log"
 	"net"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/sundae-party/pki/profile"
 	"github.com/sundae-party/pki/utils"
 )
 
@@ -42,8 +42,8 @@ var serverCertCmd = &cobra.Command{
 			return err
 		}
 
-		// Get CN from flag
-		cn, err := cmd.Flags().GetString("certCn")
+		// Get the subject from flags
+		subj, err := subjectFromFlags(cmd, "certCn")
 		if err != nil {
 			return err
 		}
@@ -56,7 +56,7 @@ var serverCertCmd = &cobra.Command{
 		formatedDurationString := fmt.Sprintf("%dh", durationString)
 		duration, err := time.ParseDuration(formatedDurationString)
 		if err != nil {
-			log.Fatalln(err)
+			return err
 		}
 
 		// Get destination folder
@@ -84,8 +84,88 @@ var serverCertCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		sansUri, sansEmail, err := uriEmailSansFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get key algorithm and size from flags
+		keyOpts, err := keyOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
 
-		return utils.CreateCertFromCAFile(caKeyPath, caCertPath, cn, duration, sansDns, sansIp, dest, certFileName, keyFileName)
+		// Get the issuance profile, its key and validity are used unless set with the flags
+		p, err := profileFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		applyProfileDefaults(cmd, p, &keyOpts, &duration)
+		if err := applyUsageFlags(cmd, &p); err != nil {
+			return err
+		}
+
+		// Get the subject key identifier method
+		skiMethod, err := skiMethodFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get CA serial registry from flags
+		serialRegistry, err := cmd.Flags().GetString("serialRegistry")
+		if err != nil {
+			return err
+		}
+
+		// Get CA chain and full chain file name from flags
+		caChainPath, err := cmd.Flags().GetString("caChain")
+		if err != nil {
+			return err
+		}
+		chainFileName, err := cmd.Flags().GetString("chainFileName")
+		if err != nil {
+			return err
+		}
+
+		// Get CA inventory and requester from flags
+		inventoryPath, requester, err := inventoryFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get CA key passphrase and new key encryption from flags
+		caKeyPass, err := passphraseFromFlag(cmd, "caKeyPass", "Enter passphrase of the CA private key", false)
+		if err != nil {
+			return err
+		}
+		keyEnc, err := keyEncryptionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		return utils.CreateCert(utils.CertOptions{
+			CAKeyPath:       caKeyPath,
+			CACertPath:      caCertPath,
+			Subject:         subj,
+			Duration:        duration,
+			SansDns:         sansDns,
+			SansIp:          sansIp,
+			SansUri:         sansUri,
+			SansEmail:       sansEmail,
+			Dest:            dest,
+			CertFileName:    certFileName,
+			KeyFileName:     keyFileName,
+			Key:             keyOpts,
+			Profile:         p,
+			SerialRegistry:  serialRegistry,
+			CAChainPath:     caChainPath,
+			ChainFileName:   chainFileName,
+			Inventory:       inventoryPath,
+			Requester:       requester,
+			CAKeyPassphrase: caKeyPass,
+			KeyEncryption:   keyEnc,
+			SKIMethod:       skiMethod,
+		})
 	},
 }
 
@@ -105,14 +185,18 @@ func init() {
 	// CA key to signe cert
 	serverCertCmd.Flags().String("caKey", "", "CA Key path used to sign the new certificate.")
 	serverCertCmd.MarkFlagRequired("caKey")
+	addCAKeyPassFlag(serverCertCmd)
 
 	// CA cert to signe cert
 	serverCertCmd.Flags().String("caCert", "", "CA Cert path used to sign the new certificate.")
 	serverCertCmd.MarkFlagRequired("caCert")
 
+	// Chain of the CA when signing with an intermediate CA
+	serverCertCmd.Flags().String("caChain", "", "Issuer chain of the CA used to sign the new certificate, when the CA is an intermediate.")
+
 	// CN
-	serverCertCmd.Flags().String("certCn", "", "Common Name to add in the new cert.")
-	serverCertCmd.MarkFlagRequired("certCn")
+	serverCertCmd.Flags().String("certCn", "", "Common Name to add in the new cert. Required unless set in --subject.")
+	addSubjectFlags(serverCertCmd)
 
 	// Destination
 	serverCertCmd.Flags().StringP("dest", "d", "ssl", "Destination where the cert and key files will be created. (default is ./ssl)")
@@ -120,14 +204,31 @@ func init() {
 	// Files name
 	serverCertCmd.Flags().String("certFileName", "srv.pem", "The cert file name. (default is srv.pem)")
 	serverCertCmd.Flags().String("keyFileName", "srv.key", "The key file name. (default is srv.key)")
+	serverCertCmd.Flags().String("chainFileName", "", "The full chain file name, the cert followed by the CA chain. Not written if empty.")
 
 	// Duration
 	serverCertCmd.Flags().Int("exp", 87600, "Time when the cert will expire from now. (default is 87600h - 10 years)")
 
+	// Key algorithm and size
+	addKeyFlags(serverCertCmd)
+	addSKIMethodFlag(serverCertCmd, "sha1")
+	addKeyOutFlags(serverCertCmd)
+
+	// Profile
+	addProfileFlag(serverCertCmd, profile.Server.Name)
+	addUsageFlags(serverCertCmd)
+
+	// Serial registry
+	serverCertCmd.Flags().String("serialRegistry", "", "Serial registry file of the CA. (default is the CA cert path with the .serials extension)")
+	addInventoryFlags(serverCertCmd)
+
 	// SANS DSN
 	serverCertCmd.Flags().StringSlice("sansDns", []string{}, "Additional dns in SANS")
 
 	// SANS IP
 	serverCertCmd.Flags().IPSlice("sansIp", []net.IP{}, "Additional IPs in SANS")
 
+	// SANS URI and email
+	addURIEmailSansFlags(serverCertCmd)
+
 }
@@ -0,0 +1,91 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/serial"
+)
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <serial>",
+	Short: "Show a certificate issued by a CA",
+	Long:  `Show the inventory record of a certificate issued by a CA, from its hexadecimal serial number.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		sn, err := serial.Parse(args[0])
+		if err != nil {
+			return err
+		}
+		inv, err := openInventoryFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		r, err := inv.Get(sn)
+		if err != nil {
+			return err
+		}
+
+		asJson, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return err
+		}
+		if asJson {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(r)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Serial:\t%s\n", r.Serial)
+		fmt.Fprintf(w, "Status:\t%s\n", r.StatusAt(time.Now()))
+		fmt.Fprintf(w, "Subject:\t%s\n", r.Subject)
+		fmt.Fprintf(w, "DNS Names:\t%s\n", strings.Join(r.DNSNames, ", "))
+		fmt.Fprintf(w, "IP Addresses:\t%s\n", strings.Join(r.IPAddresses, ", "))
+		fmt.Fprintf(w, "URIs:\t%s\n", strings.Join(r.URIs, ", "))
+		fmt.Fprintf(w, "Emails:\t%s\n", strings.Join(r.EmailAddresses, ", "))
+		fmt.Fprintf(w, "Key Algorithm:\t%s\n", r.KeyAlgorithm)
+		fmt.Fprintf(w, "Is CA:\t%t\n", r.IsCA)
+		fmt.Fprintf(w, "Not Before:\t%s\n", r.NotBefore.Format(time.RFC3339))
+		fmt.Fprintf(w, "Not After:\t%s\n", r.NotAfter.Format(time.RFC3339))
+		fmt.Fprintf(w, "Profile:\t%s\n", r.Profile)
+		fmt.Fprintf(w, "Requester:\t%s\n", r.Requester)
+		fmt.Fprintf(w, "Issued At:\t%s\n", r.IssuedAt.Format(time.RFC3339))
+		fmt.Fprintf(w, "Cert Path:\t%s\n", r.CertPath)
+		fmt.Fprintf(w, "Key Path:\t%s\n", r.KeyPath)
+		fmt.Fprintf(w, "Chain Path:\t%s\n", r.ChainPath)
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+
+	showCmd.Flags().String("caCert", "", "CA Cert path, used to find the inventory next to it.")
+	showCmd.Flags().String("inventory", "", "Inventory directory of the CA. (default is the CA cert path with the .inventory extension)")
+
+	showCmd.Flags().Bool("json", false, "Print the certificate record in json format.")
+}
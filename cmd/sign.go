@@ -0,0 +1,161 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/utils"
+)
+
+// signCmd represents the sign command
+var signCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Sign a certificate request with a CA",
+	Long: `Sign a PKCS#10 certificate request with a CA. The request signature is verified and the usages of the given profile are applied,
+a server only certificate by default. Use --profile client or peer for client certificates.
+Only the certificate is written, the private key stay on the requesting host.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get CA info from flags
+		caKeyPath, err := cmd.Flags().GetString("caKey")
+		if err != nil {
+			return err
+		}
+		caCertPath, err := cmd.Flags().GetString("caCert")
+		if err != nil {
+			return err
+		}
+		caChainPath, err := cmd.Flags().GetString("caChain")
+		if err != nil {
+			return err
+		}
+		caKeyPass, err := passphraseFromFlag(cmd, "caKeyPass", "Enter passphrase of the CA private key", false)
+		if err != nil {
+			return err
+		}
+		serialRegistry, err := cmd.Flags().GetString("serialRegistry")
+		if err != nil {
+			return err
+		}
+
+		// Get certificate request path from flags
+		csrPath, err := cmd.Flags().GetString("csr")
+		if err != nil {
+			return err
+		}
+
+		// Get profile from flags
+		p, err := profileFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Build the cert validity from flags
+		durationString, err := cmd.Flags().GetInt("exp")
+		if err != nil {
+			return err
+		}
+		duration, err := time.ParseDuration(fmt.Sprintf("%dh", durationString))
+		if err != nil {
+			return err
+		}
+		applyProfileDefaults(cmd, p, nil, &duration)
+		if err := applyUsageFlags(cmd, &p); err != nil {
+			return err
+		}
+
+		// Get the subject key identifier method
+		skiMethod, err := skiMethodFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get destination folder and files name from flags
+		dest, err := cmd.Flags().GetString("dest")
+		if err != nil {
+			return err
+		}
+		certFileName, err := cmd.Flags().GetString("certFileName")
+		if err != nil {
+			return err
+		}
+		chainFileName, err := cmd.Flags().GetString("chainFileName")
+		if err != nil {
+			return err
+		}
+
+		// Get CA inventory and requester from flags
+		inventoryPath, requester, err := inventoryFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		return utils.SignCSRFromCAFile(utils.SignCSROptions{
+			CAKeyPath:       caKeyPath,
+			CAKeyPassphrase: caKeyPass,
+			CACertPath:      caCertPath,
+			CSRPath:         csrPath,
+			Duration:        duration,
+			Profile:         p,
+			Dest:            dest,
+			CertFileName:    certFileName,
+			SerialRegistry:  serialRegistry,
+			CAChainPath:     caChainPath,
+			ChainFileName:   chainFileName,
+			Inventory:       inventoryPath,
+			Requester:       requester,
+			SKIMethod:       skiMethod,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(signCmd)
+
+	// Certificate request to sign
+	signCmd.Flags().String("csr", "", "PKCS#10 certificate request path to sign.")
+	signCmd.MarkFlagRequired("csr")
+
+	// CA used to sign the request
+	signCmd.Flags().String("caKey", "", "CA Key path used to sign the certificate request.")
+	signCmd.MarkFlagRequired("caKey")
+	addCAKeyPassFlag(signCmd)
+	signCmd.Flags().String("caCert", "", "CA Cert path used to sign the certificate request.")
+	signCmd.MarkFlagRequired("caCert")
+	signCmd.Flags().String("caChain", "", "Issuer chain of the CA used to sign the certificate request, when the CA is an intermediate.")
+	signCmd.Flags().String("serialRegistry", "", "Serial registry file of the CA. (default is the CA cert path with the .serials extension)")
+	addInventoryFlags(signCmd)
+
+	// Profile
+	addProfileFlag(signCmd, profile.Server.Name)
+	addUsageFlags(signCmd)
+	addSKIMethodFlag(signCmd, "sha1")
+
+	// Destination
+	signCmd.Flags().StringP("dest", "d", "ssl", "Destination where the cert file will be created. (default is ./ssl)")
+
+	// Files name
+	signCmd.Flags().String("certFileName", "srv.pem", "The cert file name. (default is srv.pem)")
+	signCmd.Flags().String("chainFileName", "", "The full chain file name, the cert followed by the CA chain. Not written if empty.")
+
+	// Duration
+	signCmd.Flags().Int("exp", 87600, "Time when the cert will expire from now. (default is 87600h - 10 years)")
+}
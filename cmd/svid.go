@@ -0,0 +1,228 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/spiffe"
+	"github.com/sundae-party/pki/utils"
+)
+
+// svidCmd represents the svid command
+var svidCmd = &cobra.Command{
+	Use:   "svid",
+	Short: "Create new SPIFFE X.509-SVID and key",
+	Long: `Create a new SPIFFE X.509-SVID signed by a CA, with exactly one spiffe URI SAN, used by workloads for both sides of mTLS.
+The CA should be created with --spiffeTrustDomain to pin the trust domain.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get CA info from flags
+		caKeyPath, err := cmd.Flags().GetString("caKey")
+		if err != nil {
+			return err
+		}
+		caCertPath, err := cmd.Flags().GetString("caCert")
+		if err != nil {
+			return err
+		}
+
+		// Get the SPIFFE ID from flags
+		rawID, err := cmd.Flags().GetString("spiffeId")
+		if err != nil {
+			return err
+		}
+		id, err := spiffe.ParseID(rawID)
+		if err != nil {
+			return err
+		}
+
+		// Get the optional subject from flags
+		subj, err := optionalCNSubjectFromFlags(cmd, "certCn")
+		if err != nil {
+			return err
+		}
+
+		// Get dns sans from flags
+		sansDns, err := cmd.Flags().GetStringSlice("sansDns")
+		if err != nil {
+			return err
+		}
+
+		// Build the cert validity from flags
+		durationString, err := cmd.Flags().GetInt("exp")
+		if err != nil {
+			return err
+		}
+		duration, err := time.ParseDuration(fmt.Sprintf("%dh", durationString))
+		if err != nil {
+			return err
+		}
+
+		// Get destination folder
+		dest, err := cmd.Flags().GetString("dest")
+		if err != nil {
+			return err
+		}
+
+		// Get files name from flags
+		certFileName, err := cmd.Flags().GetString("certFileName")
+		if err != nil {
+			return err
+		}
+		keyFileName, err := cmd.Flags().GetString("keyFileName")
+		if err != nil {
+			return err
+		}
+
+		// Get key algorithm and size from flags
+		keyOpts, err := keyOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get the issuance profile, its key and validity are used unless set with the flags
+		p, err := profileFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		applyProfileDefaults(cmd, p, &keyOpts, &duration)
+		if err := applyUsageFlags(cmd, &p); err != nil {
+			return err
+		}
+
+		// Get the subject key identifier method
+		skiMethod, err := skiMethodFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if !p.SVID {
+			return fmt.Errorf("profile %s is not an X.509-SVID profile", p.Name)
+		}
+
+		// Get CA serial registry from flags
+		serialRegistry, err := cmd.Flags().GetString("serialRegistry")
+		if err != nil {
+			return err
+		}
+
+		// Get CA chain and full chain file name from flags
+		caChainPath, err := cmd.Flags().GetString("caChain")
+		if err != nil {
+			return err
+		}
+		chainFileName, err := cmd.Flags().GetString("chainFileName")
+		if err != nil {
+			return err
+		}
+
+		// Get CA inventory and requester from flags
+		inventoryPath, requester, err := inventoryFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Get CA key passphrase and new key encryption from flags
+		caKeyPass, err := passphraseFromFlag(cmd, "caKeyPass", "Enter passphrase of the CA private key", false)
+		if err != nil {
+			return err
+		}
+		keyEnc, err := keyEncryptionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		return utils.CreateCert(utils.CertOptions{
+			CAKeyPath:       caKeyPath,
+			CACertPath:      caCertPath,
+			Subject:         subj,
+			SansUri:         []*url.URL{id.URL()},
+			Duration:        duration,
+			SansDns:         sansDns,
+			SansIp:          []net.IP{},
+			Dest:            dest,
+			CertFileName:    certFileName,
+			KeyFileName:     keyFileName,
+			Key:             keyOpts,
+			SerialRegistry:  serialRegistry,
+			CAChainPath:     caChainPath,
+			ChainFileName:   chainFileName,
+			Profile:         p,
+			Inventory:       inventoryPath,
+			Requester:       requester,
+			CAKeyPassphrase: caKeyPass,
+			KeyEncryption:   keyEnc,
+			SKIMethod:       skiMethod,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(svidCmd)
+
+	// CA key to signe cert
+	svidCmd.Flags().String("caKey", "", "CA Key path used to sign the new SVID.")
+	svidCmd.MarkFlagRequired("caKey")
+	addCAKeyPassFlag(svidCmd)
+
+	// CA cert to signe cert
+	svidCmd.Flags().String("caCert", "", "CA Cert path used to sign the new SVID.")
+	svidCmd.MarkFlagRequired("caCert")
+
+	// Chain of the CA when signing with an intermediate CA
+	svidCmd.Flags().String("caChain", "", "Issuer chain of the CA used to sign the new SVID, when the CA is an intermediate.")
+
+	// SPIFFE ID
+	svidCmd.Flags().String("spiffeId", "", "SPIFFE ID of the workload, like spiffe://example.org/ns/prod/sa/api.")
+	svidCmd.MarkFlagRequired("spiffeId")
+
+	// Optional subject
+	svidCmd.Flags().String("certCn", "", "Common Name to add in the new SVID. (default is an empty subject)")
+	addSubjectFlags(svidCmd)
+
+	// SANS DNS
+	svidCmd.Flags().StringSlice("sansDns", []string{}, "Additional dns in SANS")
+
+	// Destination
+	svidCmd.Flags().StringP("dest", "d", "ssl", "Destination where the SVID and key files will be created. (default is ./ssl)")
+
+	// Files name
+	svidCmd.Flags().String("certFileName", "svid.pem", "The SVID file name. (default is svid.pem)")
+	svidCmd.Flags().String("keyFileName", "svid.key", "The key file name. (default is svid.key)")
+	svidCmd.Flags().String("chainFileName", "", "The full chain file name, the SVID followed by the CA chain. Not written if empty.")
+
+	// Duration
+	svidCmd.Flags().Int("exp", 24, "Time when the SVID will expire from now. (default is 24h)")
+
+	// Key algorithm and size
+	addKeyFlags(svidCmd)
+	addSKIMethodFlag(svidCmd, "sha1")
+	addKeyOutFlags(svidCmd)
+
+	// Profile
+	addProfileFlag(svidCmd, profile.SVID.Name)
+	addUsageFlags(svidCmd)
+
+	// Serial registry
+	svidCmd.Flags().String("serialRegistry", "", "Serial registry file of the CA. (default is the CA cert path with the .serials extension)")
+	addInventoryFlags(svidCmd)
+}
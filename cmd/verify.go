@@ -0,0 +1,144 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/utils"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify a cert against trusted CAs",
+	Long: `Verify a cert as a TLS peer would: build the chain to a trusted root CA with the given intermediates,
+check the validity period, the extended key usages, the DNS name or IP address and the revocation in the given CRLs.
+The verified chain is printed on success, the failure reason otherwise.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get cert and CAs from flags
+		certPath, err := cmd.Flags().GetString("cert")
+		if err != nil {
+			return err
+		}
+		rootPaths, err := cmd.Flags().GetStringSlice("root")
+		if err != nil {
+			return err
+		}
+		intermediatePaths, err := cmd.Flags().GetStringSlice("intermediate")
+		if err != nil {
+			return err
+		}
+		crlPaths, err := cmd.Flags().GetStringSlice("crl")
+		if err != nil {
+			return err
+		}
+
+		// Get the checks from flags
+		dnsName, err := cmd.Flags().GetString("dnsName")
+		if err != nil {
+			return err
+		}
+		ipString, err := cmd.Flags().GetString("ip")
+		if err != nil {
+			return err
+		}
+		var ip net.IP
+		if ipString != "" {
+			ip = net.ParseIP(ipString)
+			if ip == nil {
+				return fmt.Errorf("invalid IP address %q", ipString)
+			}
+		}
+		usageNames, err := cmd.Flags().GetStringSlice("usage")
+		if err != nil {
+			return err
+		}
+		var usages []x509.ExtKeyUsage
+		for _, name := range usageNames {
+			usage, err := profile.ParseExtKeyUsage(name)
+			if err != nil {
+				return err
+			}
+			usages = append(usages, usage)
+		}
+		atString, err := cmd.Flags().GetString("at")
+		if err != nil {
+			return err
+		}
+		var at time.Time
+		if atString != "" {
+			at, err = time.Parse(time.RFC3339, atString)
+			if err != nil {
+				return fmt.Errorf("invalid verification time, expected RFC 3339 format like 2006-01-02T15:04:05Z: %v", err)
+			}
+		}
+
+		cmd.SilenceUsage = true
+		chains, err := utils.VerifyCertFile(utils.VerifyOptions{
+			CertPath:          certPath,
+			RootPaths:         rootPaths,
+			IntermediatePaths: intermediatePaths,
+			DNSName:           dnsName,
+			IP:                ip,
+			ExtKeyUsage:       usages,
+			At:                at,
+			CRLPaths:          crlPaths,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: verification failed: %v", certPath, err)
+		}
+
+		fmt.Printf("%s: OK\n", certPath)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for i, chain := range chains {
+			fmt.Fprintf(w, "Chain #%d\n", i+1)
+			for depth, c := range chain {
+				fmt.Fprintf(w, "  %d\t%s\t%s\tnot after %s\trevocation %s\n", depth, c.Cert.Subject, serial.Format(c.Cert.SerialNumber),
+					c.Cert.NotAfter.Format(time.RFC3339), c.Revocation)
+			}
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	// Cert and CAs
+	verifyCmd.Flags().String("cert", "", "Cert path to verify, may be followed by its intermediate CAs.")
+	verifyCmd.MarkFlagRequired("cert")
+	verifyCmd.Flags().StringSlice("root", []string{}, "Trusted root CA cert paths.")
+	verifyCmd.MarkFlagRequired("root")
+	verifyCmd.Flags().StringSlice("intermediate", []string{}, "Untrusted intermediate CA cert paths used to build the chain.")
+	verifyCmd.Flags().StringSlice("crl", []string{}, "CRL paths used to check the revocation of the certs of the chain.")
+
+	// Checks
+	verifyCmd.Flags().String("dnsName", "", "DNS name the cert must be valid for.")
+	verifyCmd.Flags().String("ip", "", "IP address the cert must be valid for.")
+	verifyCmd.Flags().StringSlice("usage", []string{}, "Extended key usages the cert must be valid for, each one is required: server, client or an extended key usage name like codeSigning. (default is any usage)")
+	verifyCmd.Flags().String("at", "", "Verification time in RFC 3339 format. (default is now)")
+}
@@ -0,0 +1,88 @@
+package crl
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"github.com/sundae-party/pki/types"
+)
+
+// Generate create a new CRL signed by the CA with all the certificates of the revocation list.
+// The CRL number of the list is incremented, the list must be saved to keep the new number.
+func Generate(ca *types.Cert, l *List, thisUpdate time.Time, validity time.Duration) (*bytes.Buffer, error) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	template := &x509.RevocationList{
+		Number:     big.NewInt(l.Number + 1),
+		ThisUpdate: thisUpdate,
+		NextUpdate: thisUpdate.Add(validity),
+	}
+	for _, e := range l.Revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   e.SerialNumber(),
+			RevocationTime: e.RevokedAt,
+			ReasonCode:     int(e.Reason),
+		})
+	}
+
+	crlBytes, err := x509.CreateRevocationList(rand.Reader, template, ca.Cert, ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	l.Number++
+
+	crlPEM := new(bytes.Buffer)
+	pem.Encode(crlPEM, &pem.Block{
+		Type:  "X509 CRL",
+		Bytes: crlBytes,
+	})
+	return crlPEM, nil
+}
+
+// LoadFromFile load a CRL in pem or der format
+func LoadFromFile(crlPath string) (*x509.RevocationList, error) {
+	crlBytes, err := ioutil.ReadFile(crlPath)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(crlBytes); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("unexpected pem type %q in CRL file", block.Type)
+		}
+		crlBytes = block.Bytes
+	}
+	return x509.ParseRevocationList(crlBytes)
+}
+
+// Check verify the CRL is signed by the issuer and is not outdated at the given time
+func Check(rl *x509.RevocationList, issuer *x509.Certificate, at time.Time) error {
+	if err := rl.CheckSignatureFrom(issuer); err != nil {
+		return err
+	}
+	if !rl.NextUpdate.IsZero() && at.After(rl.NextUpdate) {
+		return errors.New("CRL is outdated, next update was " + rl.NextUpdate.String())
+	}
+	return nil
+}
+
+// IsRevoked look for the certificate in the CRL and get its revocation entry if found
+func IsRevoked(rl *x509.RevocationList, cert *x509.Certificate) (x509.RevocationListEntry, bool) {
+	if !bytes.Equal(rl.RawIssuer, cert.RawIssuer) {
+		return x509.RevocationListEntry{}, false
+	}
+	for _, e := range rl.RevokedCertificateEntries {
+		if e.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return e, true
+		}
+	}
+	return x509.RevocationListEntry{}, false
+}
@@ -0,0 +1,197 @@
+package crl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sundae-party/pki/lockfile"
+	"github.com/sundae-party/pki/serial"
+)
+
+var (
+	// ErrAlreadyRevoked returned when revoking a certificate already revoked
+	ErrAlreadyRevoked = errors.New("certificate already revoked")
+	// ErrNotOnHold returned when releasing a certificate which is not on hold
+	ErrNotOnHold = errors.New("certificate is not on hold")
+	// ErrRevoked returned when a certificate is found revoked in a CRL
+	ErrRevoked = errors.New("certificate is revoked")
+)
+
+// Entry revoked certificate entry of a revocation list
+type Entry struct {
+	Serial    string    `json:"serial"`
+	RevokedAt time.Time `json:"revokedAt"`
+	Reason    Reason    `json:"reason"`
+}
+
+// SerialNumber get the entry serial number
+func (e Entry) SerialNumber() *big.Int {
+	sn, _ := serial.Parse(e.Serial)
+	return sn
+}
+
+// ListPath default revocation list path for the CA cert at caCertPath (ssl/ca.pem -> ssl/ca.revoked)
+func ListPath(caCertPath string) string {
+	return strings.TrimSuffix(caCertPath, filepath.Ext(caCertPath)) + ".revoked"
+}
+
+// List on disk list of the certificates revoked by a CA and number of the last generated CRL
+type List struct {
+	mu      sync.Mutex
+	path    string
+	Number  int64   `json:"crlNumber"`
+	Revoked []Entry `json:"revoked"`
+}
+
+// OpenList load the revocation list stored at path, a missing file is an empty list
+func OpenList(path string) (*List, error) {
+	l := &List{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, e := range l.Revoked {
+		if _, err := serial.Parse(e.Serial); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return l, nil
+}
+
+// Path revocation list file path
+func (l *List) Path() string {
+	return l.path
+}
+
+// Lookup get the revocation entry of a serial number
+func (l *List) Lookup(sn *big.Int) (Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := l.index(sn)
+	if i < 0 {
+		return Entry{}, false
+	}
+	return l.Revoked[i], true
+}
+
+// Entries get all the revoked certificates
+func (l *List) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Entry{}, l.Revoked...)
+}
+
+// Revoke add a serial number to the list.
+// A certificate on hold can be revoked again with a final reason, otherwise ErrAlreadyRevoked is returned.
+func (l *List) Revoke(sn *big.Int, reason Reason, at time.Time) error {
+	if reason == RemoveFromCRL {
+		return fmt.Errorf("%s is only valid in delta CRLs, use Unhold to release a certificate on hold", reason)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := Entry{
+		Serial:    serial.Format(sn),
+		RevokedAt: at.UTC(),
+		Reason:    reason,
+	}
+	i := l.index(sn)
+	if i < 0 {
+		l.Revoked = append(l.Revoked, entry)
+		return nil
+	}
+	if l.Revoked[i].Reason != CertificateHold || reason == CertificateHold {
+		return fmt.Errorf("%w: %s", ErrAlreadyRevoked, entry.Serial)
+	}
+	l.Revoked[i] = entry
+	return nil
+}
+
+// Unhold remove a certificate on hold from the list
+func (l *List) Unhold(sn *big.Int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := l.index(sn)
+	if i < 0 || l.Revoked[i].Reason != CertificateHold {
+		return fmt.Errorf("%w: %s", ErrNotOnHold, serial.Format(sn))
+	}
+	l.Revoked = append(l.Revoked[:i], l.Revoked[i+1:]...)
+	return nil
+}
+
+// UpdateList change the revocation list stored at path with fn and save it.
+// The list file is locked from its loading until it's saved, so concurrent updates by other processes
+// are not lost and each CRL number is used once. The list is not saved if fn returns an error.
+// fn must not call Save, the lock is already held.
+func UpdateList(path string, fn func(l *List) error) error {
+	unlock, err := lockfile.Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	l, err := OpenList(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(l); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.save()
+}
+
+// Save write the list to its file, under the lock of the file.
+// Use UpdateList to change a list without losing the concurrent updates.
+func (l *List) Save() error {
+	unlock, err := lockfile.Lock(l.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.save()
+}
+
+// save write the list to its file, the file lock must be held
+func (l *List) save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write in a temporary file then rename it to never leave a partial list
+	tmp := l.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func (l *List) index(sn *big.Int) int {
+	for i, e := range l.Revoked {
+		if e.SerialNumber().Cmp(sn) == 0 {
+			return i
+		}
+	}
+	return -1
+}
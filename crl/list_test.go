@@ -0,0 +1,78 @@
+package crl
+
+import (
+	"errors"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateListConcurrentRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.revoked")
+
+	// Each goroutine revokes its own serial, like concurrent revoke commands
+	const revokes = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, revokes)
+	for i := 1; i <= revokes; i++ {
+		wg.Add(1)
+		go func(sn int64) {
+			defer wg.Done()
+			errs <- UpdateList(path, func(l *List) error {
+				return l.Revoke(big.NewInt(sn), KeyCompromise, time.Now())
+			})
+		}(int64(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := OpenList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= revokes; i++ {
+		if _, ok := list.Lookup(big.NewInt(int64(i))); !ok {
+			t.Errorf("revocation of serial %d lost", i)
+		}
+	}
+}
+
+func TestUpdateList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.revoked")
+	sn := big.NewInt(42)
+
+	tests := []struct {
+		name   string
+		update func(l *List) error
+		err    error
+		want   bool
+	}{
+		{"hold", func(l *List) error { return l.Revoke(sn, CertificateHold, time.Now()) }, nil, true},
+		{"hold again", func(l *List) error { return l.Revoke(sn, CertificateHold, time.Now()) }, ErrAlreadyRevoked, true},
+		{"unhold", func(l *List) error { return l.Unhold(sn) }, nil, false},
+		{"unhold not on hold", func(l *List) error { return l.Unhold(sn) }, ErrNotOnHold, false},
+		{"revoke", func(l *List) error { return l.Revoke(sn, Superseded, time.Now()) }, nil, true},
+		{"revoke again", func(l *List) error { return l.Revoke(sn, KeyCompromise, time.Now()) }, ErrAlreadyRevoked, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := UpdateList(path, tt.update); !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			list, err := OpenList(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := list.Lookup(sn); ok != tt.want {
+				t.Errorf("serial revoked %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package crl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reason CRL reason code of a revoked certificate (RFC 5280 section 5.3.1)
+type Reason int
+
+// Reason codes, 7 is not used
+const (
+	Unspecified          Reason = 0
+	KeyCompromise        Reason = 1
+	CACompromise         Reason = 2
+	AffiliationChanged   Reason = 3
+	Superseded           Reason = 4
+	CessationOfOperation Reason = 5
+	CertificateHold      Reason = 6
+	RemoveFromCRL        Reason = 8
+	PrivilegeWithdrawn   Reason = 9
+	AACompromise         Reason = 10
+)
+
+var reasonNames = map[Reason]string{
+	Unspecified:          "unspecified",
+	KeyCompromise:        "keyCompromise",
+	CACompromise:         "cACompromise",
+	AffiliationChanged:   "affiliationChanged",
+	Superseded:           "superseded",
+	CessationOfOperation: "cessationOfOperation",
+	CertificateHold:      "certificateHold",
+	RemoveFromCRL:        "removeFromCRL",
+	PrivilegeWithdrawn:   "privilegeWithdrawn",
+	AACompromise:         "aACompromise",
+}
+
+// String get the RFC 5280 name of the reason
+func (r Reason) String() string {
+	if name, ok := reasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("reason(%d)", int(r))
+}
+
+// ParseReason get a reason from its RFC 5280 name, case insensitive
+func ParseReason(name string) (Reason, error) {
+	for r, n := range reasonNames {
+		if strings.EqualFold(n, name) {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown revocation reason %q", name)
+}
+
+// MarshalText encode the reason with its RFC 5280 name
+func (r Reason) MarshalText() ([]byte, error) {
+	if _, ok := reasonNames[r]; !ok {
+		return nil, fmt.Errorf("unknown revocation reason %d", int(r))
+	}
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decode a reason from its RFC 5280 name
+func (r *Reason) UnmarshalText(text []byte) error {
+	reason, err := ParseReason(string(text))
+	if err != nil {
+		return err
+	}
+	*r = reason
+	return nil
+}
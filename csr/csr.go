@@ -1,42 +1,184 @@
 package csr
 
 import (
+	"bytes"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"math/big"
+	"encoding/pem"
 	"net"
+	"net/url"
 	"time"
 
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/serial"
 	"github.com/sundae-party/pki/types"
 )
 
-// CreateCSR generate new CSR certificate and attached private key
-func CreateCSR(subject pkix.Name, sansDns []string, sansIP []net.IP, start time.Time, duration time.Duration) *types.Cert {
+// Options options used to create a new CSR
+type Options struct {
+	Subject pkix.Name
+	SansDns []string
+	SansIP  []net.IP
+	// SansURI URI SANs, like spiffe://trust.domain/workload
+	SansURI []*url.URL
+	// SansEmail email address SANs
+	SansEmail []string
+	Start     time.Time
+	Duration  time.Duration
+	// Key algorithm and size of the private key, the profile key or RSA 4096 if empty
+	Key types.KeyOptions
+	// Profile key usages and extensions of the certificate, profile.Peer if empty
+	Profile profile.Profile
+	// SKIMethod method used to compute the subject key identifier, keys.DefaultSKIMethod if empty
+	SKIMethod keys.SKIMethod
+}
 
-	// Gen new RSA key
-	certPrivKey, err := rsa.GenerateKey(rand.Reader, 4096)
+// CreateCSR generate new CSR certificate and attached RSA 4096 private key, it panics on error.
+//
+// Deprecated: use CreateCSRWithOptions, which returns the error.
+func CreateCSR(subject pkix.Name, sansDns []string, sansIP []net.IP, start time.Time, duration time.Duration) *types.Cert {
+	certObj, err := CreateCSRWithOptions(Options{
+		Subject:  subject,
+		SansDns:  sansDns,
+		SansIP:   sansIP,
+		Start:    start,
+		Duration: duration,
+		Key:      types.DefaultKeyOptions,
+	})
 	if err != nil {
 		panic(err)
 	}
+	return certObj
+}
+
+// CreateCSRWithOptions generate new CSR certificate and attached private key
+func CreateCSRWithOptions(opts Options) (*types.Cert, error) {
+
+	// Reject malformed SANs before generating the key
+	if err := ValidateSans(opts.SansURI, opts.SansEmail); err != nil {
+		return nil, err
+	}
+
+	// Get the profile, its key and validity are used when the options have none
+	p := opts.Profile
+	if p.Name == "" {
+		p = profile.Peer
+	}
+	keyOpts := opts.Key
+	if keyOpts == (types.KeyOptions{}) {
+		keyOpts = p.Key
+	}
+	duration := opts.Duration
+	if duration == 0 {
+		duration = p.Duration
+	}
+
+	// Gen random serial number
+	sn, err := serial.New()
+	if err != nil {
+		return nil, err
+	}
 
 	// Gen CSR template
 	csr := &x509.Certificate{
-		SerialNumber: big.NewInt(2021),
-		Subject:      subject,
-		DNSNames:     sansDns,
-		IPAddresses:  sansIP,
-		NotBefore:    start,
-		NotAfter:     start.Add(duration),
-		SubjectKeyId: []byte{1, 2, 3, 4, 5, 6},
-		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:     x509.KeyUsageDigitalSignature,
+		SerialNumber:   sn,
+		Subject:        opts.Subject,
+		DNSNames:       opts.SansDns,
+		IPAddresses:    opts.SansIP,
+		URIs:           opts.SansURI,
+		EmailAddresses: opts.SansEmail,
+		NotBefore:      opts.Start,
+		NotAfter:       opts.Start.Add(duration),
+	}
+
+	// Set key usages from the profile
+	p.Apply(csr)
+	if err := p.Check(csr); err != nil {
+		return nil, err
+	}
+
+	// Gen new private key
+	certPrivKey, err := keys.Generate(keyOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compute the subject key identifier from the public key
+	csr.SubjectKeyId, err = keys.SubjectKeyID(certPrivKey.Public(), opts.SKIMethod)
+	if err != nil {
+		return nil, err
 	}
 
 	certObj := &types.Cert{
 		Cert: csr,
 		Key:  certPrivKey,
 	}
-	return certObj
+	return certObj, nil
+}
+
+// RequestOptions options used to create a new PKCS#10 certificate request
+type RequestOptions struct {
+	Subject pkix.Name
+	SansDns []string
+	SansIP  []net.IP
+	// SansURI URI SANs, like spiffe://trust.domain/workload
+	SansURI []*url.URL
+	// SansEmail email address SANs
+	SansEmail []string
+	// Key algorithm and size of the private key, RSA 4096 if empty
+	Key types.KeyOptions
+}
+
+// CreateRequest generate new PKCS#10 certificate request signed by a new private key.
+// The request can be sent to the CA while the private key stay on the requesting host.
+func CreateRequest(opts RequestOptions) (*types.Csr, error) {
+
+	// Reject malformed SANs before generating the key
+	if err := ValidateSans(opts.SansURI, opts.SansEmail); err != nil {
+		return nil, err
+	}
+
+	// Gen new private key
+	privKey, err := keys.Generate(opts.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the request signed by the private key
+	template := &x509.CertificateRequest{
+		Subject:        opts.Subject,
+		DNSNames:       opts.SansDns,
+		IPAddresses:    opts.SansIP,
+		URIs:           opts.SansURI,
+		EmailAddresses: opts.SansEmail,
+	}
+	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, template, privKey)
+	if err != nil {
+		return nil, err
+	}
+	req, err := x509.ParseCertificateRequest(csrBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	csrPEM := new(bytes.Buffer)
+	pem.Encode(csrPEM, &pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: csrBytes,
+	})
+
+	privKeyPEM, err := keys.EncodePEM(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	csrObj := &types.Csr{
+		CsrPem: csrPEM,
+		KeyPem: privKeyPEM,
+		Csr:    req,
+		Key:    privKey,
+	}
+	return csrObj, nil
 }
@@ -0,0 +1,103 @@
+package csr
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// ParseURIs parse the URI SANs given as strings, each URI is validated by ValidateURI
+func ParseURIs(rawURIs []string) ([]*url.URL, error) {
+	var uris []*url.URL
+	for _, raw := range rawURIs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URI SAN %q: %v", raw, err)
+		}
+		if err := ValidateURI(u); err != nil {
+			return nil, err
+		}
+		uris = append(uris, u)
+	}
+	return uris, nil
+}
+
+// ValidateURI check the URI can be used as a SAN, RFC 5280 requires an absolute ASCII URI
+// with a scheme and a scheme specific part
+func ValidateURI(u *url.URL) error {
+	s := u.String()
+	if !isASCII(s) {
+		return fmt.Errorf("invalid URI SAN %q: non ASCII characters must be percent encoded", s)
+	}
+	if u.Scheme == "" {
+		return fmt.Errorf("invalid URI SAN %q: the URI must be absolute with a scheme", s)
+	}
+	if u.Opaque == "" && u.Host == "" && u.Path == "" {
+		return fmt.Errorf("invalid URI SAN %q: missing scheme specific part", s)
+	}
+	if strings.ContainsAny(s, " \t\r\n") {
+		return fmt.Errorf("invalid URI SAN %q: white spaces must be percent encoded", s)
+	}
+	if host := u.Hostname(); host != "" && net.ParseIP(host) == nil && !isDomain(host) {
+		return fmt.Errorf("invalid URI SAN %q: the host must be a domain name or an IP address", s)
+	}
+	return nil
+}
+
+// isDomain check name is made of non empty labels of ASCII letters, digits, hyphens and underscores
+func isDomain(name string) bool {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ValidateEmail check the address can be used as an email SAN, a bare ASCII local@domain address
+func ValidateEmail(email string) error {
+	if !isASCII(email) {
+		return fmt.Errorf("invalid email SAN %q: only ASCII addresses are supported", email)
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return fmt.Errorf("invalid email SAN %q: expected a local@domain address", email)
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 {
+		return fmt.Errorf("invalid email SAN %q: expected a local@domain address", email)
+	}
+	return nil
+}
+
+// ValidateSans check the URI and email SANs before they are signed
+func ValidateSans(uris []*url.URL, emails []string) error {
+	for _, u := range uris {
+		if err := ValidateURI(u); err != nil {
+			return err
+		}
+	}
+	for _, email := range emails {
+		if err := ValidateEmail(email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
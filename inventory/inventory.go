@@ -0,0 +1,251 @@
+package inventory
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/serial"
+)
+
+var (
+	// ErrNotFound returned when a serial number is not in the inventory
+	ErrNotFound = errors.New("certificate not found in inventory")
+	// ErrExists returned when adding a serial number already in the inventory
+	ErrExists = errors.New("certificate already in inventory")
+)
+
+// Status of an issued certificate
+type Status string
+
+const (
+	// StatusValid certificate issued and not revoked
+	StatusValid Status = "valid"
+	// StatusRevoked certificate revoked
+	StatusRevoked Status = "revoked"
+	// StatusSuperseded certificate replaced by a renewed certificate
+	StatusSuperseded Status = "superseded"
+	// StatusExpired certificate past its NotAfter date, never stored but computed by Record.StatusAt
+	StatusExpired Status = "expired"
+)
+
+// Record issued certificate entry of the inventory
+type Record struct {
+	Serial         string    `json:"serial"`
+	Subject        string    `json:"subject"`
+	DNSNames       []string  `json:"dnsNames,omitempty"`
+	IPAddresses    []string  `json:"ipAddresses,omitempty"`
+	URIs           []string  `json:"uris,omitempty"`
+	EmailAddresses []string  `json:"emailAddresses,omitempty"`
+	KeyAlgorithm   string    `json:"keyAlgorithm"`
+	IsCA           bool      `json:"isCA"`
+	NotBefore      time.Time `json:"notBefore"`
+	NotAfter       time.Time `json:"notAfter"`
+	Profile        string    `json:"profile,omitempty"`
+	Requester      string    `json:"requester,omitempty"`
+	IssuedAt       time.Time `json:"issuedAt"`
+	CertPath       string    `json:"certPath,omitempty"`
+	KeyPath        string    `json:"keyPath,omitempty"`
+	ChainPath      string    `json:"chainPath,omitempty"`
+	Status         Status    `json:"status"`
+}
+
+// NewRecord build a valid record from an issued certificate
+func NewRecord(cert *x509.Certificate) Record {
+	r := Record{
+		Serial:         serial.Format(cert.SerialNumber),
+		Subject:        cert.Subject.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		KeyAlgorithm:   keys.Describe(cert.PublicKey),
+		IsCA:           cert.IsCA,
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+		IssuedAt:       time.Now().UTC(),
+		Status:         StatusValid,
+	}
+	for _, ip := range cert.IPAddresses {
+		r.IPAddresses = append(r.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		r.URIs = append(r.URIs, uri.String())
+	}
+	return r
+}
+
+// StatusAt get the record status at the given time, a valid certificate past its NotAfter date is expired
+func (r Record) StatusAt(at time.Time) Status {
+	if r.Status == StatusValid && at.After(r.NotAfter) {
+		return StatusExpired
+	}
+	return r.Status
+}
+
+// Path default inventory directory for the CA cert at caCertPath (ssl/ca.pem -> ssl/ca.inventory)
+func Path(caCertPath string) string {
+	return strings.TrimSuffix(caCertPath, filepath.Ext(caCertPath)) + ".inventory"
+}
+
+// Inventory on disk inventory of the certificates issued by a CA, stored as one json file per serial number
+type Inventory struct {
+	dir string
+}
+
+// Open open the inventory stored in dir, the directory is created if missing
+func Open(dir string) (*Inventory, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Inventory{dir: dir}, nil
+}
+
+// Dir inventory directory
+func (i *Inventory) Dir() string {
+	return i.dir
+}
+
+func (i *Inventory) recordPath(sn *big.Int) string {
+	return filepath.Join(i.dir, serial.Format(sn)+".json")
+}
+
+// Add store a new record, ErrExists is returned if the serial is already in the inventory
+func (i *Inventory) Add(r Record) error {
+	sn, err := serial.Parse(r.Serial)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(i.recordPath(sn), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return fmt.Errorf("%w: %s", ErrExists, r.Serial)
+	}
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Get load the record of a serial number, ErrNotFound is returned if the serial is not in the inventory
+func (i *Inventory) Get(sn *big.Int) (Record, error) {
+	var r Record
+	data, err := ioutil.ReadFile(i.recordPath(sn))
+	if os.IsNotExist(err) {
+		return r, fmt.Errorf("%w: %s", ErrNotFound, serial.Format(sn))
+	}
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("%s: %v", i.recordPath(sn), err)
+	}
+	return r, nil
+}
+
+// Update replace an existing record
+func (i *Inventory) Update(r Record) error {
+	sn, err := serial.Parse(r.Serial)
+	if err != nil {
+		return err
+	}
+	if _, err := i.Get(sn); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write in a temporary file then rename it to never leave a partial record
+	tmp := i.recordPath(sn) + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, i.recordPath(sn))
+}
+
+// SetStatus change the status of a record
+func (i *Inventory) SetStatus(sn *big.Int, status Status) error {
+	r, err := i.Get(sn)
+	if err != nil {
+		return err
+	}
+	r.Status = status
+	return i.Update(r)
+}
+
+// Filter select the records returned by List, empty fields match all records
+type Filter struct {
+	// Status of the records at the Now time
+	Status Status
+	// Subject substring
+	Subject string
+	// Profile name
+	Profile string
+	// ExpiresBefore only keep the records with a NotAfter date before this time
+	ExpiresBefore time.Time
+	// Now time used to compute the status, time.Now() if zero
+	Now time.Time
+}
+
+// match check if the record match the filter
+func (f Filter) match(r Record) bool {
+	now := f.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if f.Status != "" && r.StatusAt(now) != f.Status {
+		return false
+	}
+	if f.Subject != "" && !strings.Contains(r.Subject, f.Subject) {
+		return false
+	}
+	if f.Profile != "" && r.Profile != f.Profile {
+		return false
+	}
+	if !f.ExpiresBefore.IsZero() && !r.NotAfter.Before(f.ExpiresBefore) {
+		return false
+	}
+	return true
+}
+
+// List get the records matching the filter, in issuance order
+func (i *Inventory) List(filter Filter) ([]Record, error) {
+	files, err := filepath.Glob(filepath.Join(i.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if filter.match(r) {
+			records = append(records, r)
+		}
+	}
+
+	sort.Slice(records, func(a, b int) bool {
+		return records[a].IssuedAt.Before(records[b].IssuedAt)
+	})
+	return records, nil
+}
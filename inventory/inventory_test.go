@@ -0,0 +1,134 @@
+package inventory
+
+import (
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAddDuplicate(t *testing.T) {
+	inv, err := Open(filepath.Join(t.TempDir(), "ca.inventory"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Record{Serial: "0A", Subject: "CN=app", Status: StatusValid}
+	if err := inv.Add(r); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same serial in an other format is the same record
+	tests := []struct {
+		name   string
+		serial string
+		err    error
+	}{
+		{"same serial", "0A", ErrExists},
+		{"lower case", "0a", ErrExists},
+		{"hex prefix", "0x0a", ErrExists},
+		{"other serial", "0B", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := inv.Add(Record{Serial: tt.serial, Subject: "CN=other", Status: StatusValid})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	// The first record is kept
+	got, err := inv.Get(big.NewInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Subject != "CN=app" {
+		t.Errorf("record subject %q, want CN=app", got.Subject)
+	}
+}
+
+func TestUpdateAndSetStatus(t *testing.T) {
+	inv, err := Open(filepath.Join(t.TempDir(), "ca.inventory"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notAfter := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := inv.Add(Record{Serial: "2A", Subject: "CN=app", NotAfter: notAfter, Status: StatusValid}); err != nil {
+		t.Fatal(err)
+	}
+	sn := big.NewInt(42)
+	missing := big.NewInt(43)
+
+	tests := []struct {
+		name   string
+		change func() error
+		err    error
+		want   Status
+	}{
+		{"revoke", func() error { return inv.SetStatus(sn, StatusRevoked) }, nil, StatusRevoked},
+		{"release", func() error { return inv.SetStatus(sn, StatusValid) }, nil, StatusValid},
+		{"supersede", func() error { return inv.SetStatus(sn, StatusSuperseded) }, nil, StatusSuperseded},
+		{"update", func() error {
+			return inv.Update(Record{Serial: "2A", Subject: "CN=app", Profile: "server", NotAfter: notAfter, Status: StatusValid})
+		}, nil, StatusValid},
+		{"set status of a missing serial", func() error { return inv.SetStatus(missing, StatusRevoked) }, ErrNotFound, StatusValid},
+		{"update a missing serial", func() error { return inv.Update(Record{Serial: "2B", Status: StatusRevoked}) }, ErrNotFound, StatusValid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			r, err := inv.Get(sn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Status != tt.want {
+				t.Errorf("status %s, want %s", r.Status, tt.want)
+			}
+			if !r.NotAfter.Equal(notAfter) {
+				t.Errorf("not after %s, want %s", r.NotAfter, notAfter)
+			}
+		})
+	}
+
+	// Update replaced the record, the missing serials were not created
+	r, err := inv.Get(sn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Profile != "server" {
+		t.Errorf("profile %q, want server", r.Profile)
+	}
+	if _, err := inv.Get(missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+	records, err := inv.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Errorf("inventory has %d records, want 1", len(records))
+	}
+}
+
+func TestStatusAt(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		record Record
+		want   Status
+	}{
+		{"valid", Record{NotAfter: now.Add(time.Hour), Status: StatusValid}, StatusValid},
+		{"expired", Record{NotAfter: now.Add(-time.Hour), Status: StatusValid}, StatusExpired},
+		{"revoked and expired", Record{NotAfter: now.Add(-time.Hour), Status: StatusRevoked}, StatusRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.record.StatusAt(now); got != tt.want {
+				t.Errorf("status %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package keys
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/sundae-party/pki/types"
+)
+
+// ParseAlgorithm get the key algorithm from its name (rsa, ecdsa or ed25519)
+func ParseAlgorithm(name string) (types.KeyAlgorithm, error) {
+	switch strings.ToLower(name) {
+	case "", "rsa":
+		return types.RSA, nil
+	case "ecdsa", "ec":
+		return types.ECDSA, nil
+	case "ed25519":
+		return types.Ed25519, nil
+	}
+	return "", fmt.Errorf("unsupported key algorithm %q, expected rsa, ecdsa or ed25519", name)
+}
+
+// Generate create a new private key with the given algorithm and size
+func Generate(opts types.KeyOptions) (crypto.Signer, error) {
+	switch opts.Algorithm {
+	case "", types.RSA:
+		size := opts.Size
+		if size == 0 {
+			size = types.DefaultKeyOptions.Size
+		}
+		if size < 2048 {
+			return nil, fmt.Errorf("RSA key size must be at least 2048 bits, got %d", size)
+		}
+		return rsa.GenerateKey(rand.Reader, size)
+	case types.ECDSA:
+		curve, err := curve(opts.Size)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case types.Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key algorithm %q", opts.Algorithm)
+}
+
+func curve(size int) (elliptic.Curve, error) {
+	switch size {
+	case 0, 256:
+		return elliptic.P256(), nil
+	case 384:
+		return elliptic.P384(), nil
+	case 521:
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("unsupported ECDSA curve size %d, expected 256, 384 or 521", size)
+}
+
+// EncodePEM get the private key in pem format.
+// RSA keys are encoded in PKCS#1, ECDSA keys in SEC1 and Ed25519 keys in PKCS#8.
+func EncodePEM(key crypto.Signer) (*bytes.Buffer, error) {
+	var block *pem.Block
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(k),
+		}
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		block = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		block = &pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}
+	}
+
+	keyPEM := new(bytes.Buffer)
+	if err := pem.Encode(keyPEM, block); err != nil {
+		return nil, err
+	}
+	return keyPEM, nil
+}
+
+// ParsePrivateKey parse a DER private key according to its pem block type
+// (RSA PRIVATE KEY, EC PRIVATE KEY or PRIVATE KEY)
+func ParsePrivateKey(blockType string, der []byte) (crypto.Signer, error) {
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported PKCS#8 private key of type %T", key)
+		}
+		return signer, nil
+	}
+	return nil, fmt.Errorf("unsupported private key pem type %q", blockType)
+}
+
+// Describe get the algorithm and size of a public key, like RSA-4096, ECDSA-P256 or Ed25519
+func Describe(pub crypto.PublicKey) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA-%s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return fmt.Sprintf("%T", pub)
+}
+
+// OptionsOf get the key options matching the algorithm and size of a public key
+func OptionsOf(pub crypto.PublicKey) (types.KeyOptions, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return types.KeyOptions{Algorithm: types.RSA, Size: k.N.BitLen()}, nil
+	case *ecdsa.PublicKey:
+		return types.KeyOptions{Algorithm: types.ECDSA, Size: k.Curve.Params().BitSize}, nil
+	case ed25519.PublicKey:
+		return types.KeyOptions{Algorithm: types.Ed25519}, nil
+	}
+	return types.KeyOptions{}, fmt.Errorf("unsupported public key of type %T", pub)
+}
@@ -0,0 +1,81 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrKeyMismatch returned when a private key does not match the public key of a certificate
+var ErrKeyMismatch = errors.New("private key does not match the certificate public key")
+
+// ErrNoPrivateKey returned when no private key is found in the data
+var ErrNoPrivateKey = errors.New("no private key found")
+
+// Parse parse a private key in pem or der format.
+// PKCS#1 RSA keys, SEC1 EC keys, PKCS#8 keys and encrypted PKCS#8 keys are supported in both formats,
+// legacy encrypted pem keys are also supported. The passphrase is only used for encrypted keys.
+// Pem blocks which are not private keys, like EC PARAMETERS or CERTIFICATE, are skipped.
+func Parse(data []byte, passphrase []byte) (crypto.Signer, error) {
+	block, rest := pem.Decode(data)
+	if block == nil {
+		return parseDER(data, passphrase)
+	}
+
+	for ; block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "RSA PRIVATE KEY", "EC PRIVATE KEY", "PRIVATE KEY":
+			return parsePEMBlock(block, passphrase)
+		case "ENCRYPTED PRIVATE KEY":
+			return DecryptPKCS8(block.Bytes, passphrase)
+		}
+	}
+	return nil, ErrNoPrivateKey
+}
+
+// parsePEMBlock parse a clear or legacy RFC 1423 encrypted private key pem block
+func parsePEMBlock(block *pem.Block, passphrase []byte) (crypto.Signer, error) {
+	der := block.Bytes
+	if x509.IsEncryptedPEMBlock(block) {
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		var err error
+		der, err = x509.DecryptPEMBlock(block, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt private key: %v", err)
+		}
+	}
+	key, err := ParsePrivateKey(block.Type, der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", block.Type, err)
+	}
+	return key, nil
+}
+
+// parseDER parse a der private key, trying PKCS#8, PKCS#1, SEC1 then encrypted PKCS#8
+func parseDER(der []byte, passphrase []byte) (crypto.Signer, error) {
+	for _, blockType := range []string{"PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY"} {
+		if key, err := ParsePrivateKey(blockType, der); err == nil {
+			return key, nil
+		}
+	}
+
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err == nil && len(rest) == 0 && info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return DecryptPKCS8(der, passphrase)
+	}
+	return nil, errors.New("not a PKCS#1, PKCS#8 or SEC1 private key in pem or der format")
+}
+
+// CheckMatch check the private key is the key of the public key
+func CheckMatch(key crypto.Signer, pub crypto.PublicKey) error {
+	keyPub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !keyPub.Equal(pub) {
+		return ErrKeyMismatch
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package keys
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF key derivation function used to derive the encryption key of a private key from a passphrase
+type KDF string
+
+const (
+	// Scrypt RFC 7914 scrypt with N=16384, r=8, p=1, the openssl defaults
+	Scrypt KDF = "scrypt"
+	// PBKDF2 RFC 8018 PBKDF2 with HMAC-SHA256 and 600000 iterations
+	PBKDF2 KDF = "pbkdf2"
+)
+
+const (
+	scryptN          = 16384
+	scryptR          = 8
+	scryptP          = 1
+	pbkdf2Iterations = 600000
+	saltSize         = 16
+)
+
+// ErrPassphraseRequired returned when reading an encrypted private key without passphrase
+var ErrPassphraseRequired = errors.New("private key is encrypted, a passphrase is required")
+
+// ErrWrongPassphrase returned when an encrypted private key can't be decrypted with the passphrase
+var ErrWrongPassphrase = errors.New("failed to decrypt private key, wrong passphrase")
+
+var (
+	oidPBES2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	oidHMACSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// encryptedPrivateKeyInfo RFC 5958 EncryptedPrivateKeyInfo
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params RFC 8018 PBES2-params
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params RFC 8018 PBKDF2-params
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// scryptParams RFC 7914 scrypt-params
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
+}
+
+// ParseKDF get the key derivation function from its name (scrypt or pbkdf2)
+func ParseKDF(name string) (KDF, error) {
+	switch strings.ToLower(name) {
+	case "", "scrypt":
+		return Scrypt, nil
+	case "pbkdf2":
+		return PBKDF2, nil
+	}
+	return "", fmt.Errorf("unsupported key derivation function %q, expected scrypt or pbkdf2", name)
+}
+
+// Encryption passphrase encryption of a private key written in pem format
+type Encryption struct {
+	// Passphrase used to encrypt the key, the key is written in clear if empty
+	Passphrase []byte
+	// KDF used to derive the encryption key from the passphrase, scrypt if empty
+	KDF KDF
+}
+
+// EncodePEM get the private key in pem format, encrypted in PKCS#8 if a passphrase is set
+// or in clear with the format of the package level EncodePEM otherwise
+func (e Encryption) EncodePEM(key crypto.Signer) (*bytes.Buffer, error) {
+	if len(e.Passphrase) == 0 {
+		return EncodePEM(key)
+	}
+	return EncryptPEM(key, e.Passphrase, e.KDF)
+}
+
+// EncryptPEM get the private key in encrypted PKCS#8 pem format (PBES2 with AES-256-CBC)
+func EncryptPEM(key crypto.Signer, passphrase []byte, kdf KDF) (*bytes.Buffer, error) {
+	encryptedDer, err := EncryptPKCS8(key, passphrase, kdf)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM := new(bytes.Buffer)
+	if err := pem.Encode(keyPEM, &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedDer}); err != nil {
+		return nil, err
+	}
+	return keyPEM, nil
+}
+
+// EncryptPKCS8 get the private key in encrypted PKCS#8 der format (PBES2 with AES-256-CBC)
+func EncryptPKCS8(key crypto.Signer, passphrase []byte, kdf KDF) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	// Derive the AES-256 key from the passphrase
+	var encKey []byte
+	var kdfAlgo pkix.AlgorithmIdentifier
+	switch kdf {
+	case "", Scrypt:
+		encKey, err = scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, 32)
+		if err != nil {
+			return nil, err
+		}
+		kdfAlgo, err = algorithmIdentifier(oidScrypt, scryptParams{
+			Salt:                     salt,
+			CostParameter:            scryptN,
+			BlockSize:                scryptR,
+			ParallelizationParameter: scryptP,
+			KeyLength:                32,
+		})
+	case PBKDF2:
+		encKey = pbkdf2.Key(passphrase, salt, pbkdf2Iterations, 32, sha256.New)
+		kdfAlgo, err = algorithmIdentifier(oidPBKDF2, pbkdf2Params{
+			Salt:           salt,
+			IterationCount: pbkdf2Iterations,
+			KeyLength:      32,
+			PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACSHA256, Parameters: asn1.NullRawValue},
+		})
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function %q", kdf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Encrypt the PKCS#8 key with PKCS#7 padding
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	plain := append(der, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, plain)
+
+	encAlgo, err := algorithmIdentifier(oidAES256CBC, iv)
+	if err != nil {
+		return nil, err
+	}
+	pbes2, err := algorithmIdentifier(oidPBES2, pbes2Params{
+		KeyDerivationFunc: kdfAlgo,
+		EncryptionScheme:  encAlgo,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pbes2,
+		EncryptedData: encrypted,
+	})
+}
+
+// DecryptPKCS8 decrypt a PBES2 encrypted PKCS#8 private key in der format
+func DecryptPKCS8(der []byte, passphrase []byte) (crypto.Signer, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrPassphraseRequired
+	}
+
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("invalid encrypted PKCS#8 private key: %v", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported PKCS#8 encryption algorithm %v, only PBES2 is supported", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("invalid PBES2 parameters: %v", err)
+	}
+
+	// Get the cipher key size and iv
+	var keySize int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keySize = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keySize = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keySize = 32
+	default:
+		return nil, fmt.Errorf("unsupported PBES2 encryption scheme %v", params.EncryptionScheme.Algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.New("invalid PBES2 encryption scheme iv")
+	}
+
+	// Derive the cipher key from the passphrase
+	encKey, err := deriveKey(params.KeyDerivationFunc, passphrase, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted private key length")
+	}
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, info.EncryptedData)
+
+	// Remove the PKCS#7 padding, a bad padding is most likely a wrong passphrase
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plain[len(plain)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, ErrWrongPassphrase
+	}
+
+	signer, err := ParsePrivateKey("PRIVATE KEY", plain[:len(plain)-padding])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWrongPassphrase, err)
+	}
+	return signer, nil
+}
+
+// deriveKey derive a cipher key of keySize bytes with the PBES2 key derivation function
+func deriveKey(kdf pkix.AlgorithmIdentifier, passphrase []byte, keySize int) ([]byte, error) {
+	switch {
+	case kdf.Algorithm.Equal(oidScrypt):
+		var params scryptParams
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("invalid scrypt parameters: %v", err)
+		}
+		return scrypt.Key(passphrase, params.Salt, params.CostParameter, params.BlockSize, params.ParallelizationParameter, keySize)
+	case kdf.Algorithm.Equal(oidPBKDF2):
+		var params pbkdf2Params
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("invalid PBKDF2 parameters: %v", err)
+		}
+		prf, err := prfHash(params.PRF.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		return pbkdf2.Key(passphrase, params.Salt, params.IterationCount, keySize, prf), nil
+	}
+	return nil, fmt.Errorf("unsupported PBES2 key derivation function %v", kdf.Algorithm)
+}
+
+// prfHash get the hash of the PBKDF2 pseudo random function, HMAC-SHA1 by default
+func prfHash(oid asn1.ObjectIdentifier) (func() hash.Hash, error) {
+	switch {
+	case len(oid) == 0, oid.Equal(oidHMACSHA1):
+		return sha1.New, nil
+	case oid.Equal(oidHMACSHA256):
+		return sha256.New, nil
+	case oid.Equal(oidHMACSHA384):
+		return sha512.New384, nil
+	case oid.Equal(oidHMACSHA512):
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported PBKDF2 pseudo random function %v", oid)
+}
+
+// algorithmIdentifier build an algorithm identifier with the der encoded parameters
+func algorithmIdentifier(oid asn1.ObjectIdentifier, params interface{}) (pkix.AlgorithmIdentifier, error) {
+	der, err := asn1.Marshal(params)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.RawValue{FullBytes: der}}, nil
+}
@@ -0,0 +1,80 @@
+package keys
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+// SKIMethod method used to compute the subject key identifier of a public key
+type SKIMethod string
+
+const (
+	// SKISHA1 RFC 5280 method 1, SHA-1 hash of the subjectPublicKey bit string
+	SKISHA1 SKIMethod = "sha1"
+	// SKISHA256 RFC 7093 method 1, leftmost 160 bits of the SHA-256 hash of the subjectPublicKey bit string
+	SKISHA256 SKIMethod = "sha256"
+)
+
+// DefaultSKIMethod method used when none is set
+const DefaultSKIMethod = SKISHA1
+
+// ParseSKIMethod get the subject key identifier method from its name (sha1 or sha256).
+// An empty name gives an empty method, replaced by the default method when the identifier is computed.
+func ParseSKIMethod(name string) (SKIMethod, error) {
+	switch SKIMethod(strings.ToLower(name)) {
+	case "":
+		return "", nil
+	case SKISHA1:
+		return SKISHA1, nil
+	case SKISHA256:
+		return SKISHA256, nil
+	}
+	return "", fmt.Errorf("unsupported subject key identifier method %q, must be sha1 or sha256", name)
+}
+
+// SubjectKeyID compute the subject key identifier of the public key, with the default method if empty
+func SubjectKeyID(pub crypto.PublicKey, method SKIMethod) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	var spki struct {
+		Algorithm        pkix.AlgorithmIdentifier
+		SubjectPublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case "", SKISHA1:
+		sum := sha1.Sum(spki.SubjectPublicKey.Bytes)
+		return sum[:], nil
+	case SKISHA256:
+		sum := sha256.Sum256(spki.SubjectPublicKey.Bytes)
+		return sum[:20], nil
+	}
+	return nil, fmt.Errorf("unsupported subject key identifier method %q", method)
+}
+
+// SKIMethodOf find the method used to compute the subject key identifier of the certificate,
+// empty if the identifier is not derived from the public key by a known method
+func SKIMethodOf(cert *x509.Certificate) SKIMethod {
+	if len(cert.SubjectKeyId) == 0 {
+		return ""
+	}
+	for _, method := range []SKIMethod{SKISHA1, SKISHA256} {
+		id, err := SubjectKeyID(cert.PublicKey, method)
+		if err == nil && bytes.Equal(id, cert.SubjectKeyId) {
+			return method
+		}
+	}
+	return ""
+}
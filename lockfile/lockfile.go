@@ -0,0 +1,37 @@
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLocked returned when a lock file is not released in time
+var ErrLocked = errors.New("file is locked")
+
+// Timeout maximum wait for a lock file held by an other process
+const Timeout = 10 * time.Second
+
+// Lock create the lock file of the file at path (path.lock), waiting up to Timeout while an other process holds it.
+// The lock file is created with O_EXCL, so it works on every platform and file system.
+// The returned function removes the lock file.
+func Lock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(Timeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			fmt.Fprintln(f, os.Getpid())
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s exists, remove it if no other pki command is running", ErrLocked, lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,324 @@
+package profile
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/subject"
+	"github.com/sundae-party/pki/types"
+)
+
+// Config profile definition of the config file, the profiles are defined by name under the profiles key:
+//
+//	profiles:
+//	  grpc-server:
+//	    keyAlgo: ecdsa
+//	    validity: 90d
+//	    keyUsage: [digitalSignature]
+//	    extKeyUsage: [serverAuth]
+//	    subject: "O=team,OU=prod"
+//	    allowedSans:
+//	      dns: ["*.svc.cluster.local"]
+//	      ip: ["10.0.0.0/8"]
+//	    extensions:
+//	      - oid: 1.2.3.4
+//	        value: "0500"
+type Config struct {
+	// KeyAlgo and KeySize of the new private keys
+	KeyAlgo string `mapstructure:"keyAlgo" yaml:"keyAlgo"`
+	KeySize int    `mapstructure:"keySize" yaml:"keySize"`
+	// Validity of the certificates, a Go duration like 2160h or a number of days like 90d
+	Validity string   `mapstructure:"validity" yaml:"validity"`
+	KeyUsage []string `mapstructure:"keyUsage" yaml:"keyUsage"`
+	// ExtKeyUsage extended key usage names, or dotted OIDs for custom usages
+	ExtKeyUsage []string `mapstructure:"extKeyUsage" yaml:"extKeyUsage"`
+	// Subject default subject attributes in RFC 4514 format
+	Subject     string            `mapstructure:"subject" yaml:"subject"`
+	AllowedSANs SANPatternsConfig `mapstructure:"allowedSans" yaml:"allowedSans"`
+	Extensions  []ExtensionConfig `mapstructure:"extensions" yaml:"extensions"`
+	SVID        bool              `mapstructure:"svid" yaml:"svid"`
+}
+
+// SANPatternsConfig allowed SAN patterns of a config profile
+type SANPatternsConfig struct {
+	DNS   []string `mapstructure:"dns" yaml:"dns"`
+	IP    []string `mapstructure:"ip" yaml:"ip"`
+	URI   []string `mapstructure:"uri" yaml:"uri"`
+	Email []string `mapstructure:"email" yaml:"email"`
+}
+
+// ExtensionConfig extra extension of a config profile
+type ExtensionConfig struct {
+	OID      string `mapstructure:"oid" yaml:"oid"`
+	Critical bool   `mapstructure:"critical" yaml:"critical"`
+	// Value DER encoded value of the extension in hexadecimal
+	Value string `mapstructure:"value" yaml:"value"`
+}
+
+// SANPatterns patterns the SANs of the certificates must match.
+// DNS, URI and email patterns use the path.Match syntax, a * in DNS patterns only matching a single label.
+// IP patterns are ranges. When a pattern is set, the SANs of a type without patterns are refused.
+type SANPatterns struct {
+	DNS   []string
+	IP    []*net.IPNet
+	URI   []string
+	Email []string
+}
+
+var (
+	customMu sync.RWMutex
+	// custom profiles added with Register
+	custom = map[string]Profile{}
+)
+
+// FromConfig build the profile name from its config file definition
+func FromConfig(name string, c Config) (Profile, error) {
+	p := Profile{Name: name, SVID: c.SVID}
+	wrap := func(err error) (Profile, error) {
+		return Profile{}, fmt.Errorf("invalid profile %s: %v", name, err)
+	}
+
+	if c.KeyAlgo != "" || c.KeySize != 0 {
+		algo, err := keys.ParseAlgorithm(c.KeyAlgo)
+		if err != nil {
+			return wrap(err)
+		}
+		p.Key = types.KeyOptions{Algorithm: algo, Size: c.KeySize}
+	}
+
+	if c.Validity != "" {
+		d, err := ParseValidity(c.Validity)
+		if err != nil {
+			return wrap(err)
+		}
+		p.Duration = d
+	}
+
+	ku, err := ParseKeyUsages(c.KeyUsage)
+	if err != nil {
+		return wrap(err)
+	}
+	p.KeyUsage = ku
+	p.ExtKeyUsage, p.UnknownExtKeyUsage, err = ParseExtKeyUsages(c.ExtKeyUsage)
+	if err != nil {
+		return wrap(err)
+	}
+
+	subj, err := subject.Parse(c.Subject)
+	if err != nil {
+		return wrap(err)
+	}
+	p.Subject = subj
+
+	p.AllowedSANs = SANPatterns{DNS: c.AllowedSANs.DNS, URI: c.AllowedSANs.URI, Email: c.AllowedSANs.Email}
+	for _, patterns := range [][]string{c.AllowedSANs.DNS, c.AllowedSANs.URI, c.AllowedSANs.Email} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return wrap(fmt.Errorf("invalid SAN pattern %q: %v", pattern, err))
+			}
+		}
+	}
+	for _, cidr := range c.AllowedSANs.IP {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return wrap(err)
+		}
+		p.AllowedSANs.IP = append(p.AllowedSANs.IP, ipNet)
+	}
+
+	for _, ext := range c.Extensions {
+		oid, err := parseOID(ext.OID)
+		if err != nil {
+			return wrap(err)
+		}
+		value, err := hex.DecodeString(ext.Value)
+		if err != nil {
+			return wrap(fmt.Errorf("invalid value of extension %s: %v", ext.OID, err))
+		}
+		p.ExtraExtensions = append(p.ExtraExtensions, pkix.Extension{Id: oid, Critical: ext.Critical, Value: value})
+	}
+	return p, nil
+}
+
+// LoadConfigFile register the profiles defined under the profiles key of a yaml config file
+func LoadConfigFile(configPath string) error {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Profiles map[string]Config `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", configPath, err)
+	}
+	return RegisterConfigs(file.Profiles)
+}
+
+// RegisterConfigs register the profiles of the config file definitions.
+// All the definitions are checked before any profile is registered, the error names the config key of the invalid one.
+func RegisterConfigs(configs map[string]Config) error {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	profiles := make([]Profile, 0, len(names))
+	for _, name := range names {
+		if _, ok := builtins[name]; ok {
+			return fmt.Errorf("profiles.%s: %s is a built-in profile and can't be replaced, use another name", name, name)
+		}
+		p, err := FromConfig(name, configs[name])
+		if err != nil {
+			return fmt.Errorf("profiles.%s: %v", name, err)
+		}
+		profiles = append(profiles, p)
+	}
+	for _, p := range profiles {
+		if err := Register(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Register add a profile found by Lookup, replacing the registered profile of the same name.
+// The built-in profiles can't be replaced.
+func Register(p Profile) error {
+	if p.Name == "" {
+		return fmt.Errorf("a profile name is required")
+	}
+	if _, ok := builtins[p.Name]; ok {
+		return fmt.Errorf("profile %s is a built-in profile and can't be replaced", p.Name)
+	}
+	customMu.Lock()
+	defer customMu.Unlock()
+	custom[p.Name] = p
+	return nil
+}
+
+// ParseValidity parse a validity period, a Go duration like 2160h or a number of days like 90d
+func ParseValidity(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid validity %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid validity %q", s)
+	}
+	return d, nil
+}
+
+// IsEmpty check no SAN pattern is set, all SANs are allowed
+func (s SANPatterns) IsEmpty() bool {
+	return len(s.DNS)+len(s.IP)+len(s.URI)+len(s.Email) == 0
+}
+
+// Check check the SANs of the certificate template match the patterns
+func (s SANPatterns) Check(template *x509.Certificate) error {
+	if s.IsEmpty() {
+		return nil
+	}
+	for _, name := range template.DNSNames {
+		if err := checkPatterns("DNS name", s.DNS, strings.ToLower(name), matchDNS); err != nil {
+			return err
+		}
+	}
+	for _, uri := range template.URIs {
+		if err := checkPatterns("URI", s.URI, uri.String(), matchPath); err != nil {
+			return err
+		}
+	}
+	for _, email := range template.EmailAddresses {
+		if err := checkPatterns("email address", s.Email, strings.ToLower(email), matchEmail); err != nil {
+			return err
+		}
+	}
+	for _, ip := range template.IPAddresses {
+		if len(s.IP) == 0 {
+			return fmt.Errorf("IP address SANs are not allowed, IP address %s", ip)
+		}
+		allowed := false
+		for _, r := range s.IP {
+			if r.Contains(ip) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("IP address %s is not in the allowed ranges %v", ip, s.IP)
+		}
+	}
+	return nil
+}
+
+// checkPatterns check the SAN matches one of the patterns of its type
+func checkPatterns(kind string, patterns []string, name string, match func(pattern, name string) bool) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("%s SANs are not allowed, %s %s", kind, kind, name)
+	}
+	for _, pattern := range patterns {
+		if match(pattern, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s does not match the allowed patterns %v", kind, name, patterns)
+}
+
+func matchPath(pattern string, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func matchEmail(pattern string, email string) bool {
+	return matchPath(strings.ToLower(pattern), email)
+}
+
+// matchDNS match the DNS name label by label, so a * only match a single label
+func matchDNS(pattern string, name string) bool {
+	patternLabels := strings.Split(strings.ToLower(pattern), ".")
+	labels := strings.Split(name, ".")
+	if len(patternLabels) != len(labels) {
+		return false
+	}
+	for i := range labels {
+		if ok, _ := path.Match(patternLabels[i], labels[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid = append(oid, n)
+	}
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	return oid, nil
+}
@@ -0,0 +1,150 @@
+package profile
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterConfigs(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs map[string]Config
+		// wantErr substring of the error, empty if no error is expected
+		wantErr string
+		// registered profiles found by Lookup after the call
+		registered []string
+		// notRegistered profiles which must not be found by Lookup after the call
+		notRegistered []string
+	}{
+		{
+			name: "valid profiles",
+			configs: map[string]Config{
+				"cfg-valid-a": {Validity: "90d", ExtKeyUsage: []string{"serverAuth"}},
+				"cfg-valid-b": {KeyUsage: []string{"digitalSignature"}},
+			},
+			registered: []string{"cfg-valid-a", "cfg-valid-b"},
+		},
+		{
+			name:    "built-in name",
+			configs: map[string]Config{"server": {Validity: "1d"}},
+			wantErr: "profiles.server: server is a built-in profile",
+		},
+		{
+			name: "built-in name prevents the other registrations",
+			configs: map[string]Config{
+				"cfg-with-builtin": {Validity: "1d"},
+				"svid":             {Validity: "1d"},
+			},
+			wantErr:       "profiles.svid:",
+			notRegistered: []string{"cfg-with-builtin"},
+		},
+		{
+			name:    "invalid validity",
+			configs: map[string]Config{"cfg-bad-validity": {Validity: "soon"}},
+			wantErr: `profiles.cfg-bad-validity: invalid profile cfg-bad-validity: invalid validity "soon"`,
+		},
+		{
+			name:    "invalid key usage",
+			configs: map[string]Config{"cfg-bad-usage": {KeyUsage: []string{"signEverything"}}},
+			wantErr: "profiles.cfg-bad-usage:",
+		},
+		{
+			name: "first invalid name in order",
+			configs: map[string]Config{
+				"cfg-z": {Validity: "soon"},
+				"cfg-a": {Validity: "later"},
+			},
+			wantErr:       "profiles.cfg-a:",
+			notRegistered: []string{"cfg-z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterConfigs(tt.configs)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("RegisterConfigs() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("RegisterConfigs() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+			for _, name := range tt.registered {
+				if _, err := Lookup(name); err != nil {
+					t.Errorf("Lookup(%s) error: %v", name, err)
+				}
+			}
+			for _, name := range tt.notRegistered {
+				if _, err := Lookup(name); err == nil {
+					t.Errorf("Lookup(%s) found a profile registered by the failed call", name)
+				}
+			}
+		})
+	}
+
+	// The built-in profile is still the built-in one
+	p, err := Lookup("server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.ExtKeyUsage) != 1 || p.ExtKeyUsage[0] != x509.ExtKeyUsageServerAuth {
+		t.Errorf("built-in server profile replaced, extended key usages %v", p.ExtKeyUsage)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `profiles:
+  cfg-file-grpc:
+    validity: 30d
+    extKeyUsage: [serverAuth]
+`,
+		},
+		{
+			name: "built-in name",
+			content: `profiles:
+  peer:
+    validity: 30d
+`,
+			wantErr: "profiles.peer:",
+		},
+		{
+			name:    "invalid yaml",
+			content: "profiles: [",
+			wantErr: "invalid config file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pki.yaml")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			err := LoadConfigFile(path)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("LoadConfigFile() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("LoadConfigFile() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	p, err := Lookup("cfg-file-grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Duration != 30*24*time.Hour {
+		t.Errorf("cfg-file-grpc duration = %s, want 720h", p.Duration)
+	}
+}
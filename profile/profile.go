@@ -0,0 +1,140 @@
+package profile
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sundae-party/pki/spiffe"
+	"github.com/sundae-party/pki/subject"
+	"github.com/sundae-party/pki/types"
+)
+
+// Profile key usages and extensions applied on the certificates issued with it
+type Profile struct {
+	Name        string
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+	// UnknownExtKeyUsage custom extended key usage OIDs
+	UnknownExtKeyUsage []asn1.ObjectIdentifier
+	ExtraExtensions    []pkix.Extension
+	// SVID the certificates must be SPIFFE X.509-SVIDs with exactly one spiffe URI SAN, see spiffe.CheckLeaf
+	SVID bool
+	// Key algorithm and size of the new private keys, used when no key option is given
+	Key types.KeyOptions
+	// Duration validity of the certificates, used when no duration is given
+	Duration time.Duration
+	// Subject default attributes of the certificate subjects
+	Subject pkix.Name
+	// AllowedSANs patterns the SANs of the certificates must match, all SANs are allowed if empty
+	AllowedSANs SANPatterns
+}
+
+// OidOcspNoCheck id-pkix-ocsp-nocheck extension, tell clients to not check the revocation of an OCSP responder cert (RFC 6960 section 4.2.2.2.1)
+var OidOcspNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+
+var (
+	// Server TLS server certificate
+	Server = Profile{
+		Name:        "server",
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	// Client mTLS client certificate
+	Client = Profile{
+		Name:        "client",
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	// Peer certificate usable both as TLS server and mTLS client certificate
+	Peer = Profile{
+		Name:        "peer",
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	// Ocsp delegated OCSP responder certificate
+	Ocsp = Profile{
+		Name:        "ocsp",
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		ExtraExtensions: []pkix.Extension{
+			// Value is an ASN.1 NULL
+			{Id: OidOcspNoCheck, Value: []byte{0x05, 0x00}},
+		},
+	}
+	// SVID SPIFFE X.509-SVID leaf certificate used by workloads for both sides of mTLS
+	SVID = Profile{
+		Name:        "svid",
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		SVID:        true,
+	}
+)
+
+var builtins = map[string]Profile{
+	Server.Name: Server,
+	Client.Name: Client,
+	Peer.Name:   Peer,
+	Ocsp.Name:   Ocsp,
+	SVID.Name:   SVID,
+}
+
+// Lookup get a built-in or registered profile by name
+func Lookup(name string) (Profile, error) {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	p, ok := builtins[name]
+	if !ok {
+		p, ok = custom[name]
+	}
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q, available profiles are %v", name, Names())
+	}
+	return p, nil
+}
+
+// Names get the names of all available profiles, built-in and registered
+func Names() []string {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	names := make([]string, 0, len(builtins)+len(custom))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply set the profile key usages, extensions and default subject attributes on the certificate template
+func (p Profile) Apply(template *x509.Certificate) {
+	template.Subject = subject.WithDefaults(template.Subject, p.Subject)
+	template.KeyUsage = p.KeyUsage
+	template.ExtKeyUsage = append([]x509.ExtKeyUsage{}, p.ExtKeyUsage...)
+	template.UnknownExtKeyUsage = append([]asn1.ObjectIdentifier{}, p.UnknownExtKeyUsage...)
+	template.ExtraExtensions = append(template.ExtraExtensions, p.ExtraExtensions...)
+
+	// X.509-SVID leaves explicitly set the cA basic constraint to false
+	if p.SVID {
+		template.IsCA = false
+		template.BasicConstraintsValid = true
+	}
+}
+
+// Check check the certificate template meets the profile requirements, once the profile is applied
+func (p Profile) Check(template *x509.Certificate) error {
+	if err := p.AllowedSANs.Check(template); err != nil {
+		return fmt.Errorf("profile %s: %v", p.Name, err)
+	}
+	if p.SVID {
+		if _, err := spiffe.CheckLeaf(template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
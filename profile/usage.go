@@ -0,0 +1,137 @@
+package profile
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+// keyUsageNames RFC 5280 names of the key usages, in bit order
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+// extKeyUsageNames RFC 5280 names of the extended key usages
+var extKeyUsageNames = []struct {
+	usage x509.ExtKeyUsage
+	name  string
+}{
+	{x509.ExtKeyUsageAny, "any"},
+	{x509.ExtKeyUsageServerAuth, "serverAuth"},
+	{x509.ExtKeyUsageClientAuth, "clientAuth"},
+	{x509.ExtKeyUsageCodeSigning, "codeSigning"},
+	{x509.ExtKeyUsageEmailProtection, "emailProtection"},
+	{x509.ExtKeyUsageIPSECEndSystem, "ipsecEndSystem"},
+	{x509.ExtKeyUsageIPSECTunnel, "ipsecTunnel"},
+	{x509.ExtKeyUsageIPSECUser, "ipsecUser"},
+	{x509.ExtKeyUsageTimeStamping, "timeStamping"},
+	{x509.ExtKeyUsageOCSPSigning, "OCSPSigning"},
+	{x509.ExtKeyUsageMicrosoftServerGatedCrypto, "msSGC"},
+	{x509.ExtKeyUsageNetscapeServerGatedCrypto, "nsSGC"},
+	{x509.ExtKeyUsageMicrosoftCommercialCodeSigning, "msCodeCom"},
+	{x509.ExtKeyUsageMicrosoftKernelCodeSigning, "msKernelCodeSigning"},
+}
+
+// KeyUsageNames get the names of the key usages set in ku
+func KeyUsageNames(ku x509.KeyUsage) []string {
+	var names []string
+	for _, n := range keyUsageNames {
+		if ku&n.usage != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// ExtKeyUsageNames get the names of the extended key usages, followed by the OIDs of the unknown usages
+func ExtKeyUsageNames(ekus []x509.ExtKeyUsage, unknown []asn1.ObjectIdentifier) []string {
+	var names []string
+	for _, eku := range ekus {
+		name := "unknown"
+		for _, n := range extKeyUsageNames {
+			if n.usage == eku {
+				name = n.name
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	for _, oid := range unknown {
+		names = append(names, oid.String())
+	}
+	return names
+}
+
+// ParseExtKeyUsages get the extended key usages from their names, or from their dotted OIDs for the custom usages
+func ParseExtKeyUsages(names []string) ([]x509.ExtKeyUsage, []asn1.ObjectIdentifier, error) {
+	var ekus []x509.ExtKeyUsage
+	var custom []asn1.ObjectIdentifier
+	for _, name := range names {
+		if strings.Contains(name, ".") {
+			oid, err := parseOID(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			custom = append(custom, oid)
+			continue
+		}
+		eku, err := ParseExtKeyUsage(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		ekus = append(ekus, eku)
+	}
+	return ekus, custom, nil
+}
+
+// ParseExtKeyUsage get an extended key usage from its name, like serverAuth or clientAuth.
+// The server and client short names are also accepted.
+func ParseExtKeyUsage(name string) (x509.ExtKeyUsage, error) {
+	switch strings.ToLower(name) {
+	case "server":
+		return x509.ExtKeyUsageServerAuth, nil
+	case "client":
+		return x509.ExtKeyUsageClientAuth, nil
+	}
+	for _, n := range extKeyUsageNames {
+		if strings.EqualFold(n.name, name) {
+			return n.usage, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown extended key usage %q", name)
+}
+
+// ParseKeyUsages get the key usages from their RFC 5280 names
+func ParseKeyUsages(names []string) (x509.KeyUsage, error) {
+	var ku x509.KeyUsage
+	for _, name := range names {
+		usage, err := ParseKeyUsage(name)
+		if err != nil {
+			return 0, err
+		}
+		ku |= usage
+	}
+	return ku, nil
+}
+
+// ParseKeyUsage get a key usage from its RFC 5280 name, like digitalSignature or keyCertSign
+func ParseKeyUsage(name string) (x509.KeyUsage, error) {
+	for _, n := range keyUsageNames {
+		if strings.EqualFold(n.name, name) {
+			return n.usage, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown key usage %q", name)
+}
@@ -0,0 +1,194 @@
+package responder
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/sundae-party/pki/crl"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/types"
+)
+
+// maxRequestSize upper bound of an OCSP request body
+const maxRequestSize = 64 * 1024
+
+// Responder RFC 6960 OCSP responder for the certificates issued by a CA managed with this tool.
+// The revocation list and serial registry are read on every request to serve the latest status.
+type Responder struct {
+	issuer *x509.Certificate
+	signer *types.Cert
+	// RevocationListPath revocation list of the CA
+	RevocationListPath string
+	// SerialRegistryPath optional serial registry of the CA, serials not in the registry get the unknown status
+	SerialRegistryPath string
+	// Validity time until the next update set in the responses
+	Validity time.Duration
+	// ErrorLog optional logger of the requests which failed, the errors are discarded if nil
+	ErrorLog *log.Logger
+}
+
+// New create a responder for the certificates issued by issuer.
+// The signer is the CA itself or a delegated responder cert issued by the CA with the OCSP signing usage.
+func New(issuer *x509.Certificate, signer *types.Cert, revocationListPath string, serialRegistryPath string, validity time.Duration) (*Responder, error) {
+	if issuer == nil {
+		return nil, errors.New("the CA certificate is required")
+	}
+	if signer == nil || signer.Cert == nil {
+		return nil, errors.New("the responder certificate is required")
+	}
+	if signer.Key == nil {
+		return nil, fmt.Errorf("%w: the private key of the responder is required", keys.ErrNoPrivateKey)
+	}
+	if err := keys.CheckMatch(signer.Key, signer.Cert.PublicKey); err != nil {
+		return nil, err
+	}
+	if !signer.Cert.Equal(issuer) {
+		if err := signer.Cert.CheckSignatureFrom(issuer); err != nil {
+			return nil, fmt.Errorf("responder cert is not issued by the CA: %v", err)
+		}
+		if !hasOcspSigning(signer.Cert) {
+			return nil, errors.New("responder cert has not the OCSP signing extended key usage")
+		}
+	}
+	r := &Responder{
+		issuer:             issuer,
+		signer:             signer,
+		RevocationListPath: revocationListPath,
+		SerialRegistryPath: serialRegistryPath,
+		Validity:           validity,
+	}
+	return r, nil
+}
+
+func hasOcspSigning(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			return true
+		}
+	}
+	return false
+}
+
+// Respond build the signed OCSP response of a DER OCSP request
+func (r *Responder) Respond(reqBytes []byte, now time.Time) ([]byte, error) {
+	req, err := ocsp.ParseRequest(reqBytes)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse, err
+	}
+
+	// Only answer for the certificates of our CA
+	if ok, err := r.isIssuer(req); err != nil {
+		return ocsp.InternalErrorErrorResponse, err
+	} else if !ok {
+		return ocsp.UnauthorizedErrorResponse, fmt.Errorf("request for serial %s of an other issuer", serial.Format(req.SerialNumber))
+	}
+
+	template := ocsp.Response{
+		SerialNumber: req.SerialNumber,
+		Status:       ocsp.Good,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(r.Validity),
+	}
+	if !r.signer.Cert.Equal(r.issuer) {
+		template.Certificate = r.signer.Cert
+	}
+
+	// Get the certificate status
+	list, err := crl.OpenList(r.RevocationListPath)
+	if err != nil {
+		return ocsp.InternalErrorErrorResponse, err
+	}
+	if entry, revoked := list.Lookup(req.SerialNumber); revoked {
+		template.Status = ocsp.Revoked
+		template.RevokedAt = entry.RevokedAt
+		template.RevocationReason = int(entry.Reason)
+	} else if r.SerialRegistryPath != "" {
+		registry, err := serial.OpenRegistry(r.SerialRegistryPath)
+		if err != nil {
+			return ocsp.InternalErrorErrorResponse, err
+		}
+		if !registry.Contains(req.SerialNumber) {
+			template.Status = ocsp.Unknown
+		}
+	}
+
+	resp, err := ocsp.CreateResponse(r.issuer, r.signer.Cert, template, r.signer.Key)
+	if err != nil {
+		return ocsp.InternalErrorErrorResponse, err
+	}
+	return resp, nil
+}
+
+// isIssuer check the request issuer name and key hashes match the CA
+func (r *Responder) isIssuer(req *ocsp.Request) (bool, error) {
+	if !req.HashAlgorithm.Available() {
+		return false, fmt.Errorf("unsupported request hash algorithm %v", req.HashAlgorithm)
+	}
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(r.issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false, err
+	}
+
+	nameHash := hash(req.HashAlgorithm, r.issuer.RawSubject)
+	keyHash := hash(req.HashAlgorithm, spki.PublicKey.RightAlign())
+	return bytes.Equal(nameHash, req.IssuerNameHash) && bytes.Equal(keyHash, req.IssuerKeyHash), nil
+}
+
+func hash(h crypto.Hash, data []byte) []byte {
+	hasher := h.New()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+// ServeHTTP answer OCSP requests sent with POST or base64 encoded in the GET url path (RFC 6960 appendix A)
+func (r *Responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var reqBytes []byte
+	var err error
+	switch req.Method {
+	case http.MethodGet:
+		var path string
+		path, err = url.PathUnescape(strings.TrimPrefix(req.URL.EscapedPath(), "/"))
+		if err == nil {
+			reqBytes, err = base64.StdEncoding.DecodeString(path)
+		}
+	case http.MethodPost:
+		reqBytes, err = ioutil.ReadAll(io.LimitReader(req.Body, maxRequestSize))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var resp []byte
+	if err != nil {
+		resp = ocsp.MalformedRequestErrorResponse
+	} else {
+		resp, err = r.Respond(reqBytes, time.Now())
+	}
+	if err != nil && r.ErrorLog != nil {
+		r.ErrorLog.Printf("OCSP request from %s: %v", req.RemoteAddr, err)
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
@@ -0,0 +1,251 @@
+package responder
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/crl"
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestNew(t *testing.T) {
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "responder test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := keys.Generate(types.KeyOptions{Algorithm: types.ECDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		issuer  *x509.Certificate
+		signer  *types.Cert
+		wantErr bool
+		// is optional error expected in the error chain
+		is error
+	}{
+		{"CA signer", root.Cert, root, false, nil},
+		{"nil issuer", nil, root, true, nil},
+		{"nil signer", root.Cert, nil, true, nil},
+		{"nil signer cert", root.Cert, &types.Cert{Key: root.Key}, true, nil},
+		{"nil signer key", root.Cert, &types.Cert{Cert: root.Cert}, true, keys.ErrNoPrivateKey},
+		{"mismatched signer key", root.Cert, &types.Cert{Cert: root.Cert, Key: other}, true, keys.ErrKeyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.issuer, tt.signer, "", "", time.Hour)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("got error %v, want %v", err, tt.is)
+			}
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "responder test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "ca.revoked")
+	registryPath := filepath.Join(dir, "ca.serials")
+	registry, err := serial.OpenRegistry(registryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Issue the good, revoked and unknown certificates, the unknown one is not in the registry
+	issue := func(cn string, register bool) *x509.Certificate {
+		leaf, err := csr.CreateCSRWithOptions(csr.Options{
+			Subject:  pkix.Name{CommonName: cn},
+			Start:    time.Now(),
+			Duration: time.Hour,
+			Key:      types.KeyOptions{Algorithm: types.ECDSA},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ca.SignCert(root, leaf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if register {
+			if err := registry.Register(cert.Cert.SerialNumber); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return cert.Cert
+	}
+	good := issue("good", true)
+	revoked := issue("revoked", true)
+	unknown := issue("unknown", false)
+	err = crl.UpdateList(listPath, func(l *crl.List) error {
+		return l.Revoke(revoked.SerialNumber, crl.KeyCompromise, time.Now().Add(-time.Second))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(root.Cert, root, listPath, registryPath, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		cert   *x509.Certificate
+		status int
+	}{
+		{"good", good, ocsp.Good},
+		{"revoked", revoked, ocsp.Revoked},
+		{"unknown", unknown, ocsp.Unknown},
+	}
+	for _, tt := range tests {
+		req, err := ocsp.CreateRequest(tt.cert, root.Cert, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			t.Run(tt.name+" "+method, func(t *testing.T) {
+				var resp *http.Response
+				var err error
+				if method == http.MethodGet {
+					resp, err = http.Get(server.URL + "/" + url.PathEscape(base64.StdEncoding.EncodeToString(req)))
+				} else {
+					resp, err = http.Post(server.URL, "application/ocsp-request", bytes.NewReader(req))
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				if ct := resp.Header.Get("Content-Type"); ct != "application/ocsp-response" {
+					t.Errorf("content type %q, want application/ocsp-response", ct)
+				}
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parsed, err := ocsp.ParseResponseForCert(body, tt.cert, root.Cert)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if parsed.Status != tt.status {
+					t.Errorf("status %d, want %d", parsed.Status, tt.status)
+				}
+				if tt.status == ocsp.Revoked && parsed.RevocationReason != ocsp.KeyCompromise {
+					t.Errorf("revocation reason %d, want keyCompromise", parsed.RevocationReason)
+				}
+			})
+		}
+	}
+}
+
+func TestServeHTTPErrors(t *testing.T) {
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "responder test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoot, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "other root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(root.Cert, root, filepath.Join(t.TempDir(), "ca.revoked"), "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged bytes.Buffer
+	r.ErrorLog = log.New(&logged, "", 0)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	otherReq, err := ocsp.CreateRequest(otherRoot.Cert, otherRoot.Cert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   []byte
+		want   []byte
+		code   int
+	}{
+		{"malformed request", http.MethodPost, []byte("garbage"), ocsp.MalformedRequestErrorResponse, http.StatusOK},
+		{"other issuer", http.MethodPost, otherReq, ocsp.UnauthorizedErrorResponse, http.StatusOK},
+		{"method not allowed", http.MethodPut, nil, nil, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged.Reset()
+			req, err := http.NewRequest(tt.method, server.URL, bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.code {
+				t.Fatalf("status code %d, want %d", resp.StatusCode, tt.code)
+			}
+			if tt.want == nil {
+				return
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(body, tt.want) {
+				t.Errorf("response %x, want %x", body, tt.want)
+			}
+			if logged.Len() == 0 {
+				t.Errorf("error not reported to the error log")
+			}
+		})
+	}
+}
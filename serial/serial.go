@@ -0,0 +1,189 @@
+package serial
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sundae-party/pki/lockfile"
+)
+
+// ErrSerialExists returned when a serial number is already recorded in a registry
+var ErrSerialExists = errors.New("serial number already issued")
+
+// ErrRegistryLocked returned when the lock of a registry file is not released in time, same error as lockfile.ErrLocked
+var ErrRegistryLocked = lockfile.ErrLocked
+
+// serialLimit upper bound of generated serial numbers, 159 bits keep the DER encoding under the 20 octets limit of RFC 5280
+var serialLimit = new(big.Int).Lsh(big.NewInt(1), 159)
+
+// New generate a random positive serial number of up to 159 bits
+func New() (*big.Int, error) {
+	for {
+		sn, err := rand.Int(rand.Reader, serialLimit)
+		if err != nil {
+			return nil, err
+		}
+		if sn.Sign() > 0 {
+			return sn, nil
+		}
+	}
+}
+
+// Format get the serial number in upper case hexadecimal format
+func Format(sn *big.Int) string {
+	return fmt.Sprintf("%X", sn)
+}
+
+// Parse read a serial number in hexadecimal format, "0x" prefix and ":" separators are allowed
+func Parse(s string) (*big.Int, error) {
+	hex := strings.ReplaceAll(strings.TrimSpace(s), ":", "")
+	hex = strings.TrimPrefix(strings.TrimPrefix(hex, "0x"), "0X")
+	sn, ok := new(big.Int).SetString(hex, 16)
+	if !ok || sn.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid serial number %q", s)
+	}
+	return sn, nil
+}
+
+// RegistryPath default registry path for the CA cert at caCertPath (ssl/ca.pem -> ssl/ca.serials)
+func RegistryPath(caCertPath string) string {
+	return strings.TrimSuffix(caCertPath, filepath.Ext(caCertPath)) + ".serials"
+}
+
+// Registry on disk record of every serial number issued by a CA, one hexadecimal serial per line.
+// Registrations are serialized between processes with a lock file next to the registry, and the registry file
+// is replaced atomically so it can be read without the lock.
+type Registry struct {
+	mu      sync.Mutex
+	path    string
+	serials []*big.Int
+	index   map[string]struct{}
+}
+
+// OpenRegistry load the registry stored at path, the file is created on the first registered serial
+func OpenRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load read the registry file, a missing file is an empty registry
+func (r *Registry) load() error {
+	r.serials = nil
+	r.index = map[string]struct{}{}
+
+	f, err := os.Open(r.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		sn, err := Parse(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", r.path, line, err)
+		}
+		r.serials = append(r.serials, sn)
+		r.index[Format(sn)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Path registry file path
+func (r *Registry) Path() string {
+	return r.path
+}
+
+// Contains check if the serial number is already issued
+func (r *Registry) Contains(sn *big.Int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.index[Format(sn)]
+	return ok
+}
+
+// Serials get all issued serial numbers in issuance order
+func (r *Registry) Serials() []*big.Int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	serials := make([]*big.Int, len(r.serials))
+	copy(serials, r.serials)
+	return serials
+}
+
+// Register record a serial number, ErrSerialExists is returned if the serial is already issued
+func (r *Registry) Register(sn *big.Int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Lock the file until the new serial is saved, so concurrent invocations don't lose serials
+	unlock, err := lockfile.Lock(r.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Reload the file to see serials registered by other processes
+	if err := r.load(); err != nil {
+		return err
+	}
+	if _, ok := r.index[Format(sn)]; ok {
+		return fmt.Errorf("%w: %s", ErrSerialExists, Format(sn))
+	}
+
+	if err := r.save(append(r.serials, sn)); err != nil {
+		return err
+	}
+
+	r.serials = append(r.serials, sn)
+	r.index[Format(sn)] = struct{}{}
+	return nil
+}
+
+// save write the serials in a temporary file then rename it to never leave a partial registry
+func (r *Registry) save(serials []*big.Int) error {
+	var b strings.Builder
+	for _, sn := range serials {
+		fmt.Fprintln(&b, Format(sn))
+	}
+	tmp := r.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// Next generate a new random serial number not yet issued and record it
+func (r *Registry) Next() (*big.Int, error) {
+	for {
+		sn, err := New()
+		if err != nil {
+			return nil, err
+		}
+		err = r.Register(sn)
+		if errors.Is(err, ErrSerialExists) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return sn, nil
+	}
+}
@@ -0,0 +1,75 @@
+package serial
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRegistryConcurrentRegister(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.serials")
+
+	// Each goroutine opens its own registry, like concurrent pki invocations
+	const workers, perWorker = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			registry, err := OpenRegistry(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for i := 0; i < perWorker; i++ {
+				if _, err := registry.Next(); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	registry, err := OpenRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(registry.Serials()); got != workers*perWorker {
+		t.Errorf("registry has %d serials, want %d", got, workers*perWorker)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left after the registrations: %v", err)
+	}
+}
+
+func TestRegistryRegisterExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.serials")
+	registry, err := OpenRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sn := big.NewInt(42)
+	if err := registry.Register(sn); err != nil {
+		t.Fatal(err)
+	}
+
+	// An other registry sees the serial saved by the first one
+	other, err := OpenRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !other.Contains(sn) {
+		t.Errorf("serial %s not found in the reopened registry", Format(sn))
+	}
+	if err := other.Register(sn); !errors.Is(err, ErrSerialExists) {
+		t.Errorf("got error %v, want %v", err, ErrSerialExists)
+	}
+}
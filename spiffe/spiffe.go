@@ -0,0 +1,144 @@
+package spiffe
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Scheme URI scheme of the SPIFFE IDs
+const Scheme = "spiffe"
+
+// maxIDLength maximum length of a SPIFFE ID in bytes
+const maxIDLength = 2048
+
+var (
+	// ErrNoSPIFFEID the certificate has no spiffe URI SAN
+	ErrNoSPIFFEID = errors.New("no SPIFFE ID in certificate")
+	// ErrInvalidSVID the certificate does not follow the X.509-SVID specification
+	ErrInvalidSVID = errors.New("invalid X.509-SVID")
+)
+
+// ID SPIFFE ID, spiffe://<trust domain>/<path>
+type ID struct {
+	TrustDomain string
+	// Path of the workload, starting with / or empty for the trust domain ID
+	Path string
+}
+
+// String get the spiffe:// URI of the ID
+func (id ID) String() string {
+	return Scheme + "://" + id.TrustDomain + id.Path
+}
+
+// URL get the ID as an URI SAN
+func (id ID) URL() *url.URL {
+	return &url.URL{Scheme: Scheme, Host: id.TrustDomain, Path: id.Path}
+}
+
+// MemberOf check the ID belongs to the trust domain
+func (id ID) MemberOf(trustDomain string) bool {
+	return id.TrustDomain == strings.ToLower(trustDomain)
+}
+
+// ParseID parse and validate a SPIFFE ID like spiffe://example.org/ns/prod/sa/api
+func ParseID(s string) (ID, error) {
+	if len(s) > maxIDLength {
+		return ID{}, fmt.Errorf("invalid SPIFFE ID: longer than %d bytes", maxIDLength)
+	}
+	prefix := Scheme + "://"
+	if !strings.HasPrefix(s, prefix) {
+		return ID{}, fmt.Errorf("invalid SPIFFE ID %q: scheme must be %s://", s, Scheme)
+	}
+	rest := s[len(prefix):]
+	if strings.ContainsAny(rest, "?#") {
+		return ID{}, fmt.Errorf("invalid SPIFFE ID %q: query and fragment are not allowed", s)
+	}
+
+	td, path := rest, ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		td, path = rest[:i], rest[i:]
+	}
+	if err := ValidateTrustDomain(td); err != nil {
+		return ID{}, fmt.Errorf("invalid SPIFFE ID %q: %v", s, err)
+	}
+	if err := validatePath(path); err != nil {
+		return ID{}, fmt.Errorf("invalid SPIFFE ID %q: %v", s, err)
+	}
+	return ID{TrustDomain: td, Path: path}, nil
+}
+
+// ValidateTrustDomain check the trust domain name, made of lower case letters, digits, dots, dashes and underscores
+func ValidateTrustDomain(td string) error {
+	if td == "" {
+		return errors.New("trust domain is empty")
+	}
+	for i := 0; i < len(td); i++ {
+		c := td[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return fmt.Errorf("trust domain %q must only contain lower case letters, digits, dots, dashes and underscores", td)
+		}
+	}
+	return nil
+}
+
+// validatePath check the path segments are not empty, not relative and only use the allowed characters
+func validatePath(path string) error {
+	if path == "" {
+		return nil
+	}
+	for _, segment := range strings.Split(path[1:], "/") {
+		switch segment {
+		case "":
+			return errors.New("path segments must not be empty")
+		case ".", "..":
+			return errors.New("path segments must not be relative")
+		}
+		for i := 0; i < len(segment); i++ {
+			c := segment[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+				return fmt.Errorf("path segment %q must only contain letters, digits, dots, dashes and underscores", segment)
+			}
+		}
+	}
+	return nil
+}
+
+// FromURIs get the SPIFFE ID of the URI SANs, an X.509-SVID carries exactly one URI SAN
+func FromURIs(uris []*url.URL) (ID, error) {
+	if len(uris) == 0 {
+		return ID{}, ErrNoSPIFFEID
+	}
+	if len(uris) > 1 {
+		return ID{}, fmt.Errorf("%w: exactly one URI SAN is allowed, found %d", ErrInvalidSVID, len(uris))
+	}
+	id, err := ParseID(uris[0].String())
+	if err != nil {
+		return ID{}, fmt.Errorf("%w: %v", ErrInvalidSVID, err)
+	}
+	return id, nil
+}
+
+// CheckLeaf check the certificate is a leaf X.509-SVID and get its SPIFFE ID:
+// exactly one spiffe URI SAN with a workload path, no CA bit, the digitalSignature usage and no CA key usages
+func CheckLeaf(cert *x509.Certificate) (ID, error) {
+	id, err := FromURIs(cert.URIs)
+	if err != nil {
+		return ID{}, err
+	}
+	if id.Path == "" {
+		return ID{}, fmt.Errorf("%w: leaf SPIFFE ID %s has no path", ErrInvalidSVID, id)
+	}
+	if cert.IsCA {
+		return ID{}, fmt.Errorf("%w: leaf certificate must not be a CA", ErrInvalidSVID)
+	}
+	if cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return ID{}, fmt.Errorf("%w: digitalSignature key usage is required", ErrInvalidSVID)
+	}
+	if cert.KeyUsage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0 {
+		return ID{}, fmt.Errorf("%w: keyCertSign and cRLSign key usages are not allowed", ErrInvalidSVID)
+	}
+	return id, nil
+}
@@ -0,0 +1,152 @@
+package spiffe
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		want    ID
+		wantErr bool
+	}{
+		{name: "workload", id: "spiffe://example.org/ns/prod/sa/api", want: ID{TrustDomain: "example.org", Path: "/ns/prod/sa/api"}},
+		{name: "trust domain", id: "spiffe://example.org", want: ID{TrustDomain: "example.org"}},
+		{name: "allowed characters", id: "spiffe://a-b_c.0/A.b-c_9", want: ID{TrustDomain: "a-b_c.0", Path: "/A.b-c_9"}},
+		{name: "other scheme", id: "https://example.org/api", wantErr: true},
+		{name: "upper case scheme", id: "SPIFFE://example.org/api", wantErr: true},
+		{name: "no trust domain", id: "spiffe:///api", wantErr: true},
+		{name: "upper case trust domain", id: "spiffe://Example.org/api", wantErr: true},
+		{name: "port", id: "spiffe://example.org:8080/api", wantErr: true},
+		{name: "user info", id: "spiffe://user@example.org/api", wantErr: true},
+		{name: "query", id: "spiffe://example.org/api?x=1", wantErr: true},
+		{name: "fragment", id: "spiffe://example.org/api#x", wantErr: true},
+		{name: "trailing slash", id: "spiffe://example.org/api/", wantErr: true},
+		{name: "empty segment", id: "spiffe://example.org//api", wantErr: true},
+		{name: "dot segment", id: "spiffe://example.org/./api", wantErr: true},
+		{name: "dot dot segment", id: "spiffe://example.org/a/../api", wantErr: true},
+		{name: "percent encoding", id: "spiffe://example.org/a%20b", wantErr: true},
+		{name: "too long", id: "spiffe://example.org/" + strings.Repeat("a", maxIDLength), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseID(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseID(%q) error %v, want error %v", tt.id, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseID(%q) = %+v, want %+v", tt.id, got, tt.want)
+			}
+			if got.String() != tt.id {
+				t.Errorf("ID string %q, want %q", got.String(), tt.id)
+			}
+			if got.URL().String() != tt.id {
+				t.Errorf("ID URL %q, want %q", got.URL().String(), tt.id)
+			}
+		})
+	}
+}
+
+func TestCheckLeaf(t *testing.T) {
+	uri := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	workload := uri("spiffe://example.org/api")
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want ID
+		err  error
+	}{
+		{
+			name: "leaf",
+			cert: &x509.Certificate{URIs: []*url.URL{workload}, KeyUsage: x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment},
+			want: ID{TrustDomain: "example.org", Path: "/api"},
+		},
+		{name: "no URI SAN", cert: &x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature}, err: ErrNoSPIFFEID},
+		{
+			name: "two URI SANs",
+			cert: &x509.Certificate{URIs: []*url.URL{workload, uri("spiffe://example.org/other")}, KeyUsage: x509.KeyUsageDigitalSignature},
+			err:  ErrInvalidSVID,
+		},
+		{
+			name: "other URI scheme",
+			cert: &x509.Certificate{URIs: []*url.URL{uri("https://example.org/api")}, KeyUsage: x509.KeyUsageDigitalSignature},
+			err:  ErrInvalidSVID,
+		},
+		{
+			name: "query in path",
+			cert: &x509.Certificate{URIs: []*url.URL{uri("spiffe://example.org/api?x=1")}, KeyUsage: x509.KeyUsageDigitalSignature},
+			err:  ErrInvalidSVID,
+		},
+		{
+			name: "fragment in path",
+			cert: &x509.Certificate{URIs: []*url.URL{uri("spiffe://example.org/api#x")}, KeyUsage: x509.KeyUsageDigitalSignature},
+			err:  ErrInvalidSVID,
+		},
+		{
+			name: "trust domain ID",
+			cert: &x509.Certificate{URIs: []*url.URL{uri("spiffe://example.org")}, KeyUsage: x509.KeyUsageDigitalSignature},
+			err:  ErrInvalidSVID,
+		},
+		{
+			name: "CA",
+			cert: &x509.Certificate{URIs: []*url.URL{workload}, KeyUsage: x509.KeyUsageDigitalSignature, IsCA: true},
+			err:  ErrInvalidSVID,
+		},
+		{name: "no digitalSignature", cert: &x509.Certificate{URIs: []*url.URL{workload}, KeyUsage: x509.KeyUsageKeyEncipherment}, err: ErrInvalidSVID},
+		{
+			name: "keyCertSign",
+			cert: &x509.Certificate{URIs: []*url.URL{workload}, KeyUsage: x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign},
+			err:  ErrInvalidSVID,
+		},
+		{
+			name: "cRLSign",
+			cert: &x509.Certificate{URIs: []*url.URL{workload}, KeyUsage: x509.KeyUsageDigitalSignature | x509.KeyUsageCRLSign},
+			err:  ErrInvalidSVID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckLeaf(tt.cert)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got ID %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemberOf(t *testing.T) {
+	id := ID{TrustDomain: "example.org", Path: "/api"}
+	tests := []struct {
+		trustDomain string
+		want        bool
+	}{
+		{"example.org", true},
+		{"Example.ORG", true},
+		{"other.org", false},
+		{"org", false},
+		{"sub.example.org", false},
+	}
+	for _, tt := range tests {
+		if got := id.MemberOf(tt.trustDomain); got != tt.want {
+			t.Errorf("MemberOf(%q) = %v, want %v", tt.trustDomain, got, tt.want)
+		}
+	}
+}
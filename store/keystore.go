@@ -0,0 +1,166 @@
+package store
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/types"
+)
+
+// KeystoreType format of a Java key store or trust store
+type KeystoreType string
+
+const (
+	// JKS legacy Java key store format
+	JKS KeystoreType = "jks"
+	// PKCS12 PKCS#12 Java key store format, the default key store type since Java 9
+	PKCS12 KeystoreType = "pkcs12"
+)
+
+// minPasswordLen minimum store password length accepted by keytool
+const minPasswordLen = 6
+
+// ParseKeystoreType get the key store type from its name (jks or pkcs12)
+func ParseKeystoreType(name string) (KeystoreType, error) {
+	switch strings.ToLower(name) {
+	case "", "pkcs12", "p12":
+		return PKCS12, nil
+	case "jks":
+		return JKS, nil
+	}
+	return "", fmt.Errorf("unsupported key store type %q, expected jks or pkcs12", name)
+}
+
+// Alias get the default alias of a certificate in a Java store, the lower case common name or the serial if no common name
+func Alias(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return strings.ToLower(cert.Subject.CommonName)
+	}
+	return strings.ToLower(serial.Format(cert.SerialNumber))
+}
+
+// EncodeKeyStore write a Java key store with a single key entry named alias, holding the private key and its chain.
+// The key password protects the key entry of a JKS store, the store password is used if empty.
+// PKCS#12 stores always use the store password for the key entry, as expected by Java.
+func EncodeKeyStore(cert *types.Cert, alias string, storePassword string, keyPassword string, storeType KeystoreType) ([]byte, error) {
+	if cert.Key == nil {
+		return nil, fmt.Errorf("a private key is required to export %s in a key store", cert.Cert.Subject)
+	}
+	if len(storePassword) < minPasswordLen {
+		return nil, fmt.Errorf("key store password must be at least %d characters", minPasswordLen)
+	}
+	if alias == "" {
+		alias = Alias(cert.Cert)
+	}
+	chain := append([]*x509.Certificate{cert.Cert}, cert.Chain...)
+
+	switch storeType {
+	case "", PKCS12:
+		if keyPassword != "" && keyPassword != storePassword {
+			return nil, errors.New("PKCS#12 key stores use the store password for the key entry, the key password must be empty")
+		}
+		return encodeP12KeyStore(cert.Key, chain, alias, storePassword)
+	case JKS:
+		if keyPassword == "" {
+			keyPassword = storePassword
+		}
+		if len(keyPassword) < minPasswordLen {
+			return nil, fmt.Errorf("key password must be at least %d characters", minPasswordLen)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(cert.Key)
+		if err != nil {
+			return nil, err
+		}
+		entry := keystore.PrivateKeyEntry{
+			CreationTime:     time.Now(),
+			PrivateKey:       der,
+			CertificateChain: jksCertificates(chain),
+		}
+		ks := keystore.New(keystore.WithMinPasswordLen(minPasswordLen))
+		if err := ks.SetPrivateKeyEntry(alias, entry, []byte(keyPassword)); err != nil {
+			return nil, err
+		}
+		return storeJKS(ks, storePassword)
+	}
+	return nil, fmt.Errorf("unsupported key store type %q", storeType)
+}
+
+// TrustedEntry trusted CA certificate of a trust store
+type TrustedEntry struct {
+	// Alias of the entry, the default alias of the cert if empty
+	Alias string
+	Cert  *x509.Certificate
+}
+
+// EncodeTrustStore write a Java trust store with a trusted certificate entry per CA certificate.
+// Duplicated aliases get a numbered suffix.
+func EncodeTrustStore(entries []TrustedEntry, password string, storeType KeystoreType) ([]byte, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("at least one certificate is required in a trust store")
+	}
+	if len(password) < minPasswordLen {
+		return nil, fmt.Errorf("trust store password must be at least %d characters", minPasswordLen)
+	}
+
+	// Get unique aliases, Java aliases are case insensitive
+	used := map[string]bool{}
+	aliases := make([]string, len(entries))
+	for i, entry := range entries {
+		alias := entry.Alias
+		if alias == "" {
+			alias = Alias(entry.Cert)
+		}
+		unique := alias
+		for n := 2; used[strings.ToLower(unique)]; n++ {
+			unique = fmt.Sprintf("%s-%d", alias, n)
+		}
+		used[strings.ToLower(unique)] = true
+		aliases[i] = unique
+	}
+
+	switch storeType {
+	case "", PKCS12:
+		var p12Entries []pkcs12.TrustStoreEntry
+		for i, entry := range entries {
+			p12Entries = append(p12Entries, pkcs12.TrustStoreEntry{Cert: entry.Cert, FriendlyName: aliases[i]})
+		}
+		return pkcs12.Modern.EncodeTrustStoreEntries(p12Entries, password)
+	case JKS:
+		ks := keystore.New(keystore.WithMinPasswordLen(minPasswordLen), keystore.WithOrderedAliases())
+		for i, entry := range entries {
+			trusted := keystore.TrustedCertificateEntry{
+				CreationTime: time.Now(),
+				Certificate:  jksCertificates([]*x509.Certificate{entry.Cert})[0],
+			}
+			if err := ks.SetTrustedCertificateEntry(aliases[i], trusted); err != nil {
+				return nil, err
+			}
+		}
+		return storeJKS(ks, password)
+	}
+	return nil, fmt.Errorf("unsupported trust store type %q", storeType)
+}
+
+func jksCertificates(certs []*x509.Certificate) []keystore.Certificate {
+	var jksCerts []keystore.Certificate
+	for _, cert := range certs {
+		jksCerts = append(jksCerts, keystore.Certificate{Type: "X509", Content: cert.Raw})
+	}
+	return jksCerts
+}
+
+func storeJKS(ks keystore.KeyStore, password string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := ks.Store(buf, []byte(password)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/types"
+)
+
+// P12Encryption encryption algorithms of a PKCS#12 file
+type P12Encryption string
+
+const (
+	// P12Modern AES-256-CBC with PBKDF2 and a SHA-256 MAC, for OpenSSL 3, Java 12+ and recent macOS
+	P12Modern P12Encryption = "modern"
+	// P12Legacy 3DES with a SHA-1 MAC, for OpenSSL 1.x, older Java and macOS/iOS keychains
+	P12Legacy P12Encryption = "legacy"
+	// P12LegacyRC2 RC2-40 certificates and 3DES key with a SHA-1 MAC, for the oldest Windows and Java versions
+	P12LegacyRC2 P12Encryption = "legacy-rc2"
+)
+
+// ParseP12Encryption get the PKCS#12 encryption from its name (modern, legacy or legacy-rc2)
+func ParseP12Encryption(name string) (P12Encryption, error) {
+	switch strings.ToLower(name) {
+	case "", "modern":
+		return P12Modern, nil
+	case "legacy":
+		return P12Legacy, nil
+	case "legacy-rc2":
+		return P12LegacyRC2, nil
+	}
+	return "", fmt.Errorf("unsupported PKCS#12 encryption %q, expected modern, legacy or legacy-rc2", name)
+}
+
+func (e P12Encryption) encoder() (*pkcs12.Encoder, error) {
+	switch e {
+	case "", P12Modern:
+		return pkcs12.Modern, nil
+	case P12Legacy:
+		return pkcs12.LegacyDES, nil
+	case P12LegacyRC2:
+		return pkcs12.LegacyRC2, nil
+	}
+	return nil, fmt.Errorf("unsupported PKCS#12 encryption %q", e)
+}
+
+// EncodeP12 bundle the cert, its private key and its chain in a password protected PKCS#12 file
+func EncodeP12(cert *types.Cert, password string, encryption P12Encryption) ([]byte, error) {
+	if cert.Key == nil {
+		return nil, fmt.Errorf("a private key is required to export %s in PKCS#12", cert.Cert.Subject)
+	}
+	encoder, err := encryption.encoder()
+	if err != nil {
+		return nil, err
+	}
+	return encoder.Encode(cert.Key, cert.Cert, cert.Chain, password)
+}
+
+// DecodeP12 unpack a PKCS#12 file with a private key, its certificate and the CA chain
+func DecodeP12(data []byte, password string) (*types.Cert, error) {
+	privateKey, cert, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PKCS#12 file: %v", err)
+	}
+	key, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported PKCS#12 private key of type %T", privateKey)
+	}
+	if err := keys.CheckMatch(key, cert.PublicKey); err != nil {
+		return nil, err
+	}
+
+	certPem := new(bytes.Buffer)
+	if err := pem.Encode(certPem, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+		return nil, err
+	}
+	keyPem, err := keys.EncodePEM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	certObj := &types.Cert{
+		CertPem: certPem,
+		KeyPem:  keyPem,
+		Cert:    cert,
+		Key:     key,
+		Chain:   chain,
+	}
+	return certObj, nil
+}
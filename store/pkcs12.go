@@ -0,0 +1,250 @@
+package store
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"unicode/utf16"
+
+	"github.com/sundae-party/pki/keys"
+)
+
+// The go-pkcs12 encoder does not set the friendlyName attribute used by Java as the alias of the key entry,
+// this file write a PKCS#12 key store with a single key entry named after the alias, as written by keytool:
+// the key bag is encrypted with PBES2 (PBKDF2 with HMAC-SHA256 and AES-256-CBC), the cert bags are not
+// encrypted and the store integrity is protected by a HMAC-SHA256 MAC.
+
+const (
+	macIterations = 10000
+	// pkcs12MacKeyID diversifier of the PKCS#12 key derivation for MAC keys
+	pkcs12MacKeyID byte = 3
+)
+
+var (
+	oidDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidShroudedKeyBag  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// pfxPdu RFC 7292 PFX
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+// encodeP12KeyStore write a PKCS#12 key store with the private key and its certificate chain under alias
+func encodeP12KeyStore(key crypto.Signer, chain []*x509.Certificate, alias string, password string) ([]byte, error) {
+
+	// Attributes linking the key to its certificate, and naming the entry
+	fingerprint := sha1.Sum(chain[0].Raw)
+	localKeyID, err := attribute(oidLocalKeyID, fingerprint[:])
+	if err != nil {
+		return nil, err
+	}
+	friendlyName, err := attribute(oidFriendlyName, asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpString(alias, false)})
+	if err != nil {
+		return nil, err
+	}
+
+	// Key bag
+	encryptedKey, err := keys.EncryptPKCS8(key, []byte(password), keys.PBKDF2)
+	if err != nil {
+		return nil, err
+	}
+	keyBag := safeBag{
+		ID:         oidShroudedKeyBag,
+		Value:      explicitValue(encryptedKey),
+		Attributes: []pkcs12Attribute{localKeyID, friendlyName},
+	}
+
+	// Cert bags, the attributes are only set on the key certificate
+	var certBags []safeBag
+	for i, cert := range chain {
+		bag, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: cert.Raw})
+		if err != nil {
+			return nil, err
+		}
+		certBag := safeBag{ID: oidCertBag, Value: explicitValue(bag)}
+		if i == 0 {
+			certBag.Attributes = []pkcs12Attribute{localKeyID, friendlyName}
+		}
+		certBags = append(certBags, certBag)
+	}
+
+	// Authenticated safe with the key bag then the cert bags
+	var authSafe []contentInfo
+	for _, bags := range [][]safeBag{{keyBag}, certBags} {
+		ci, err := dataContentInfo(bags)
+		if err != nil {
+			return nil, err
+		}
+		authSafe = append(authSafe, ci)
+	}
+	authSafeDer, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	// MAC of the authenticated safe
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	macKey := pkcs12KDF(bmpString(password, true), salt, macIterations, pkcs12MacKeyID, sha256.Size)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(authSafeDer)
+
+	authSafeContent, err := asn1.Marshal(authSafeDer)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pfxPdu{
+		Version:  3,
+		AuthSafe: contentInfo{ContentType: oidDataContentType, Content: explicitValue(authSafeContent)},
+		MacData: macData{
+			Mac: digestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+				Digest:    mac.Sum(nil),
+			},
+			MacSalt:    salt,
+			Iterations: macIterations,
+		},
+	})
+}
+
+// dataContentInfo wrap the safe bags in a data content info
+func dataContentInfo(bags []safeBag) (contentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	data, err := asn1.Marshal(safeContents)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidDataContentType, Content: explicitValue(data)}, nil
+}
+
+// explicitValue wrap the der value in a [0] EXPLICIT tag, the raw values ignore the explicit field parameter
+func explicitValue(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// attribute build a PKCS#12 bag attribute with a single value
+func attribute(oid asn1.ObjectIdentifier, value interface{}) (pkcs12Attribute, error) {
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		return pkcs12Attribute{}, err
+	}
+	return pkcs12Attribute{
+		ID:    oid,
+		Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: der},
+	}, nil
+}
+
+// bmpString encode s in big endian UTF-16, with a trailing null character for the PKCS#12 passwords
+func bmpString(s string, nullTerminated bool) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	if nullTerminated {
+		b = append(b, 0, 0)
+	}
+	return b
+}
+
+// pkcs12KDF RFC 7292 appendix B.2 key derivation with SHA-256
+func pkcs12KDF(password []byte, salt []byte, iterations int, id byte, size int) []byte {
+	const u, v = sha256.Size, sha256.BlockSize
+
+	// I = S || P with the salt and the password repeated to a multiple of v bytes
+	fill := func(in []byte) []byte {
+		if len(in) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(in)+v-1)/v))
+		for i := range out {
+			out[i] = in[i%len(in)]
+		}
+		return out
+	}
+	I := append(fill(salt), fill(password)...)
+
+	D := make([]byte, v)
+	for i := range D {
+		D[i] = id
+	}
+
+	one := big.NewInt(1)
+	var derived []byte
+	for len(derived) < size {
+		h := sha256.New()
+		h.Write(D)
+		h.Write(I)
+		A := h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			sum := sha256.Sum256(A)
+			A = sum[:]
+		}
+		derived = append(derived, A...)
+
+		// I_j = (I_j + B + 1) mod 2^(v*8) with B the hash repeated to v bytes
+		B := new(big.Int).SetBytes(fill(A[:u]))
+		B.Add(B, one)
+		for j := 0; j < len(I); j += v {
+			Ij := new(big.Int).SetBytes(I[j : j+v])
+			Ij.Add(Ij, B)
+			b := Ij.Bytes()
+			if len(b) > v {
+				b = b[len(b)-v:]
+			}
+			copy(I[j:j+v], make([]byte, v))
+			copy(I[j+v-len(b):j+v], b)
+		}
+	}
+	return derived[:size]
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/types"
+)
+
+// Vectors computed with openssl kdf PKCS12KDF, digest SHA256 and id 3 (MAC key)
+func TestPKCS12KDF(t *testing.T) {
+	tests := []struct {
+		password   string
+		nullTerm   bool
+		salt       string
+		iterations int
+		size       int
+		want       string
+	}{
+		{"smeg", true, "0a58cf64530d823f", 1, 32, "b26d0cad11e901ad0ba0ce08b18df8fc49d2eb271bf02a0cee1669e01251b18d"},
+		{"changeit", false, "000102030405060708090a0b0c0d0e0f", 2048, 40, "16f097d6547551c468e4ad0e86e92dee907ccbe10d1d3f3ae6a37f1833018410bcac89e2646e6615"},
+		{"changeit", true, "000102030405060708090a0b0c0d0e0f", 10000, 32, "04f324108fb2ac9a77729c84bc77f98f6be093b823aad9102bd78e1d00cafb9c"},
+	}
+	for _, tt := range tests {
+		salt, _ := hex.DecodeString(tt.salt)
+		got := pkcs12KDF(bmpString(tt.password, tt.nullTerm), salt, tt.iterations, pkcs12MacKeyID, tt.size)
+		if hex.EncodeToString(got) != tt.want {
+			t.Errorf("pkcs12KDF(%q, %d iterations) = %x, want %s", tt.password, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeP12KeyStore(t *testing.T) {
+	ecdsaKey := types.KeyOptions{Algorithm: types.ECDSA}
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      ecdsaKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+		Subject:  pkix.Name{CommonName: "App"},
+		Start:    time.Now(),
+		Duration: time.Hour,
+		Key:      ecdsaKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ca.SignCert(root, leafCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const password = "changeit"
+	data, err := EncodeKeyStore(leaf, "", password, "", PKCS12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		t.Fatalf("decoded key is %T, want a crypto.Signer", key)
+	}
+	if err := keys.CheckMatch(signer, leaf.Cert.PublicKey); err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(cert.Raw, leaf.Cert.Raw) {
+		t.Errorf("decoded certificate %s, want %s", cert.Subject, leaf.Cert.Subject)
+	}
+	if len(caCerts) != 1 || !bytes.Equal(caCerts[0].Raw, root.Cert.Raw) {
+		t.Errorf("decoded %d CA certificates, want the root", len(caCerts))
+	}
+
+	if _, _, _, err := pkcs12.DecodeChain(data, "wrong password"); err == nil {
+		t.Error("decoding with a wrong password succeeded")
+	}
+
+	// The key and its certificate carry the alias and the certificate fingerprint
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := sha1.Sum(leaf.Cert.Raw)
+	var keyAttrs, certAttrs int
+	for _, block := range blocks {
+		if block.Headers["friendlyName"] == "" {
+			continue
+		}
+		if block.Headers["friendlyName"] != "app" {
+			t.Errorf("%s friendlyName %q, want app", block.Type, block.Headers["friendlyName"])
+		}
+		if !strings.EqualFold(block.Headers["localKeyId"], hex.EncodeToString(fingerprint[:])) {
+			t.Errorf("%s localKeyId %s, want %x", block.Type, block.Headers["localKeyId"], fingerprint)
+		}
+		if block.Type == "CERTIFICATE" {
+			certAttrs++
+		} else {
+			keyAttrs++
+		}
+	}
+	if keyAttrs != 1 || certAttrs != 1 {
+		t.Errorf("got %d keys and %d certificates with attributes, want 1 of each", keyAttrs, certAttrs)
+	}
+}
@@ -0,0 +1,346 @@
+package subject
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// attribute a distinguished name attribute known by its keyword
+type attribute struct {
+	keyword string
+	oid     asn1.ObjectIdentifier
+	// tag ASN.1 string type of the attribute values added to ExtraNames
+	tag int
+}
+
+// attributes RFC 4514 keywords, plus the ones commonly used by openssl.
+// The attributes with a pkix.Name field are set in the field, the others are added to ExtraNames.
+var attributes = []attribute{
+	{"CN", asn1.ObjectIdentifier{2, 5, 4, 3}, 0},
+	{"SERIALNUMBER", asn1.ObjectIdentifier{2, 5, 4, 5}, 0},
+	{"C", asn1.ObjectIdentifier{2, 5, 4, 6}, 0},
+	{"L", asn1.ObjectIdentifier{2, 5, 4, 7}, 0},
+	{"ST", asn1.ObjectIdentifier{2, 5, 4, 8}, 0},
+	{"S", asn1.ObjectIdentifier{2, 5, 4, 8}, 0},
+	{"STREET", asn1.ObjectIdentifier{2, 5, 4, 9}, 0},
+	{"O", asn1.ObjectIdentifier{2, 5, 4, 10}, 0},
+	{"OU", asn1.ObjectIdentifier{2, 5, 4, 11}, 0},
+	{"POSTALCODE", asn1.ObjectIdentifier{2, 5, 4, 17}, 0},
+	{"DC", asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}, asn1.TagIA5String},
+	{"UID", asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}, asn1.TagUTF8String},
+	{"EMAILADDRESS", asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}, asn1.TagIA5String},
+}
+
+// value attribute value, a string or the DER encoding given with the #hex form
+type value struct {
+	str string
+	der []byte
+}
+
+// Parse parse a RFC 4514 distinguished name string like "CN=svc,O=team,OU=prod".
+// Attribute types are keywords (CN, O, OU, C, ST, L, STREET, postalCode, serialNumber, DC, UID, emailAddress)
+// or dotted OIDs, values can be escaped with \ or given as #hex DER encoding.
+// Attributes of the same type are kept in order; the order of the different types is the pkix.Name one.
+func Parse(dn string) (pkix.Name, error) {
+	var name pkix.Name
+	p := parser{s: dn}
+	p.skipSpaces()
+	if p.done() {
+		return name, nil
+	}
+
+	for {
+		oid, tag, err := p.parseType()
+		if err != nil {
+			return pkix.Name{}, err
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return pkix.Name{}, err
+		}
+		if err := set(&name, oid, tag, v); err != nil {
+			return pkix.Name{}, err
+		}
+
+		p.skipSpaces()
+		if p.done() {
+			return name, nil
+		}
+		switch p.s[p.i] {
+		case ',', ';', '+':
+			p.i++
+			p.skipSpaces()
+		default:
+			return pkix.Name{}, fmt.Errorf("invalid subject %q: unexpected %q at position %d", dn, p.s[p.i], p.i)
+		}
+	}
+}
+
+// AddAttribute add an attribute given as TYPE=value, the type being a keyword or a dotted OID
+func AddAttribute(name *pkix.Name, attr string) error {
+	parsed, err := Parse(attr)
+	if err != nil {
+		return err
+	}
+	Merge(name, parsed)
+	return nil
+}
+
+// Merge add the attributes of other to name. The single valued attributes of other replace the ones of name.
+func Merge(name *pkix.Name, other pkix.Name) {
+	if other.CommonName != "" {
+		name.CommonName = other.CommonName
+	}
+	if other.SerialNumber != "" {
+		name.SerialNumber = other.SerialNumber
+	}
+	name.Country = append(name.Country, other.Country...)
+	name.Organization = append(name.Organization, other.Organization...)
+	name.OrganizationalUnit = append(name.OrganizationalUnit, other.OrganizationalUnit...)
+	name.Locality = append(name.Locality, other.Locality...)
+	name.Province = append(name.Province, other.Province...)
+	name.StreetAddress = append(name.StreetAddress, other.StreetAddress...)
+	name.PostalCode = append(name.PostalCode, other.PostalCode...)
+	name.ExtraNames = append(name.ExtraNames, other.ExtraNames...)
+}
+
+// ExtraAttributes get the attributes of a parsed name which have no pkix.Name field, to copy them in the
+// ExtraNames of a new name. The parsed names only fill Names, so these attributes are lost otherwise.
+// The known string attributes keep their ASN.1 string type.
+func ExtraAttributes(name pkix.Name) []pkix.AttributeTypeAndValue {
+	var extra []pkix.AttributeTypeAndValue
+	for _, attr := range name.Names {
+		if fieldOf(&pkix.Name{}, attr.Type) != nil {
+			continue
+		}
+		if str, ok := attr.Value.(string); ok {
+			for _, a := range attributes {
+				if a.oid.Equal(attr.Type) && a.tag != 0 {
+					attr.Value = asn1.RawValue{Tag: a.tag, Bytes: []byte(str)}
+					break
+				}
+			}
+		}
+		extra = append(extra, attr)
+	}
+	return extra
+}
+
+// set set the attribute in the pkix.Name field of its type, or add it to ExtraNames
+func set(name *pkix.Name, oid asn1.ObjectIdentifier, tag int, v value) error {
+	field := fieldOf(name, oid)
+	if field == nil {
+		var attrValue interface{} = v.str
+		if v.der != nil {
+			attrValue = asn1.RawValue{FullBytes: v.der}
+		} else if tag != 0 {
+			attrValue = asn1.RawValue{Tag: tag, Bytes: []byte(v.str)}
+		}
+		name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{Type: oid, Value: attrValue})
+		return nil
+	}
+
+	str := v.str
+	if v.der != nil {
+		var raw asn1.RawValue
+		if _, err := asn1.Unmarshal(v.der, &raw); err != nil {
+			return fmt.Errorf("invalid DER value of attribute %s: %v", oid, err)
+		}
+		str = string(raw.Bytes)
+	}
+	switch f := field.(type) {
+	case *string:
+		if *f != "" {
+			return fmt.Errorf("attribute %s is set more than once", oid)
+		}
+		*f = str
+	case *[]string:
+		*f = append(*f, str)
+	}
+	return nil
+}
+
+// fieldOf get the pkix.Name field of the attribute type, nil if the type has no field
+func fieldOf(name *pkix.Name, oid asn1.ObjectIdentifier) interface{} {
+	if len(oid) != 4 || oid[0] != 2 || oid[1] != 5 || oid[2] != 4 {
+		return nil
+	}
+	switch oid[3] {
+	case 3:
+		return &name.CommonName
+	case 5:
+		return &name.SerialNumber
+	case 6:
+		return &name.Country
+	case 7:
+		return &name.Locality
+	case 8:
+		return &name.Province
+	case 9:
+		return &name.StreetAddress
+	case 10:
+		return &name.Organization
+	case 11:
+		return &name.OrganizationalUnit
+	case 17:
+		return &name.PostalCode
+	}
+	return nil
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+func (p *parser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *parser) skipSpaces() {
+	for !p.done() && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
+
+// parseType parse the attribute type up to the = sign
+func (p *parser) parseType() (asn1.ObjectIdentifier, int, error) {
+	end := strings.IndexByte(p.s[p.i:], '=')
+	if end < 0 {
+		return nil, 0, fmt.Errorf("invalid subject %q: missing = after attribute type at position %d", p.s, p.i)
+	}
+	attrType := strings.TrimSpace(p.s[p.i : p.i+end])
+	p.i += end + 1
+
+	for _, a := range attributes {
+		if strings.EqualFold(a.keyword, attrType) {
+			return a.oid, a.tag, nil
+		}
+	}
+
+	// Dotted OID, optionally prefixed with OID.
+	oidString := attrType
+	if len(oidString) > 4 && strings.EqualFold(oidString[:4], "OID.") {
+		oidString = oidString[4:]
+	}
+	var oid asn1.ObjectIdentifier
+	for _, part := range strings.Split(oidString, ".") {
+		var n int
+		if _, err := fmt.Sscanf(part, "%d", &n); err != nil || fmt.Sprint(n) != part || n < 0 {
+			return nil, 0, fmt.Errorf("unknown subject attribute type %q", attrType)
+		}
+		oid = append(oid, n)
+	}
+	if len(oid) < 2 {
+		return nil, 0, fmt.Errorf("unknown subject attribute type %q", attrType)
+	}
+	for _, a := range attributes {
+		if a.oid.Equal(oid) {
+			return a.oid, a.tag, nil
+		}
+	}
+	return oid, 0, nil
+}
+
+// parseValue parse the attribute value up to the next unescaped separator
+func (p *parser) parseValue() (value, error) {
+	p.skipSpaces()
+
+	// #hex DER encoded value
+	if !p.done() && p.s[p.i] == '#' {
+		start := p.i + 1
+		for p.i++; !p.done() && !strings.ContainsRune(",;+ ", rune(p.s[p.i])); p.i++ {
+		}
+		der, err := hex.DecodeString(p.s[start:p.i])
+		if err != nil || len(der) == 0 {
+			return value{}, fmt.Errorf("invalid hex value %q in subject", p.s[start:p.i])
+		}
+		return value{der: der}, nil
+	}
+
+	var buf []byte
+	// significant length, without the trailing unescaped spaces
+	length := 0
+	for ; !p.done(); p.i++ {
+		c := p.s[p.i]
+		switch c {
+		case ',', ';', '+':
+			return p.stringValue(buf[:length])
+		case '\\':
+			if p.i+1 >= len(p.s) {
+				return value{}, fmt.Errorf("invalid subject %q: trailing escape character", p.s)
+			}
+			next := p.s[p.i+1]
+			if strings.IndexByte("\"+,;<>\\ #=", next) >= 0 {
+				buf = append(buf, next)
+				p.i++
+			} else if p.i+2 < len(p.s) && isHex(next) && isHex(p.s[p.i+2]) {
+				b, _ := hex.DecodeString(p.s[p.i+1 : p.i+3])
+				buf = append(buf, b[0])
+				p.i += 2
+			} else {
+				return value{}, fmt.Errorf("invalid subject %q: invalid escape sequence at position %d", p.s, p.i)
+			}
+			length = len(buf)
+		case '"':
+			return value{}, fmt.Errorf("invalid subject %q: quotes must be escaped", p.s)
+		default:
+			buf = append(buf, c)
+			if c != ' ' {
+				length = len(buf)
+			}
+		}
+	}
+	return p.stringValue(buf[:length])
+}
+
+func (p *parser) stringValue(b []byte) (value, error) {
+	if !utf8.Valid(b) {
+		return value{}, fmt.Errorf("invalid subject %q: value is not valid UTF-8", p.s)
+	}
+	return value{str: string(b)}, nil
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// WithDefaults get name with its empty attributes set from defaults
+func WithDefaults(name pkix.Name, defaults pkix.Name) pkix.Name {
+	if name.CommonName == "" {
+		name.CommonName = defaults.CommonName
+	}
+	if name.SerialNumber == "" {
+		name.SerialNumber = defaults.SerialNumber
+	}
+	for _, f := range []struct{ field, def *[]string }{
+		{&name.Country, &defaults.Country},
+		{&name.Organization, &defaults.Organization},
+		{&name.OrganizationalUnit, &defaults.OrganizationalUnit},
+		{&name.Locality, &defaults.Locality},
+		{&name.Province, &defaults.Province},
+		{&name.StreetAddress, &defaults.StreetAddress},
+		{&name.PostalCode, &defaults.PostalCode},
+	} {
+		if len(*f.field) == 0 {
+			*f.field = append([]string(nil), *f.def...)
+		}
+	}
+	for _, extra := range defaults.ExtraNames {
+		found := false
+		for _, attr := range name.ExtraNames {
+			if attr.Type.Equal(extra.Type) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			name.ExtraNames = append(name.ExtraNames, extra)
+		}
+	}
+	return name
+}
@@ -0,0 +1,119 @@
+package subject
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	oidDC := asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+	oidCustom := asn1.ObjectIdentifier{1, 2, 3, 4}
+
+	tests := []struct {
+		name    string
+		dn      string
+		want    pkix.Name
+		wantErr bool
+	}{
+		{name: "empty", dn: "", want: pkix.Name{}},
+		{name: "spaces only", dn: "   ", want: pkix.Name{}},
+		{
+			name: "keywords",
+			dn:   "CN=svc,O=team,OU=prod,C=FR,ST=IDF,L=Paris,STREET=1 rue,postalCode=75001,serialNumber=42",
+			want: pkix.Name{
+				CommonName: "svc", Organization: []string{"team"}, OrganizationalUnit: []string{"prod"},
+				Country: []string{"FR"}, Province: []string{"IDF"}, Locality: []string{"Paris"},
+				StreetAddress: []string{"1 rue"}, PostalCode: []string{"75001"}, SerialNumber: "42",
+			},
+		},
+		{name: "case insensitive keywords and spaces", dn: " cn = svc ; o = team ", want: pkix.Name{CommonName: "svc", Organization: []string{"team"}}},
+		{name: "repeated attributes keep their order", dn: "OU=b,OU=a,CN=svc", want: pkix.Name{CommonName: "svc", OrganizationalUnit: []string{"b", "a"}}},
+		{name: "multi-valued RDN", dn: "CN=svc+OU=prod,O=team", want: pkix.Name{CommonName: "svc", OrganizationalUnit: []string{"prod"}, Organization: []string{"team"}}},
+		{name: "escaped comma", dn: `O=Acme\, Inc.,CN=svc`, want: pkix.Name{CommonName: "svc", Organization: []string{"Acme, Inc."}}},
+		{name: "escaped plus", dn: `CN=a\+b`, want: pkix.Name{CommonName: "a+b"}},
+		{name: "escaped specials", dn: `CN=\"q\" \<x\> \; \\ \= \#`, want: pkix.Name{CommonName: `"q" <x> ; \ = #`}},
+		{name: "hex escape", dn: `O=Acme\2C Inc.`, want: pkix.Name{Organization: []string{"Acme, Inc."}}},
+		{name: "hex escaped UTF-8", dn: `L=Montr\C3\A9al`, want: pkix.Name{Locality: []string{"Montréal"}}},
+		{name: "escaped leading and trailing spaces", dn: `CN=\ svc\ `, want: pkix.Name{CommonName: " svc "}},
+		{name: "unescaped trailing spaces are dropped", dn: "CN=svc   ,O=team", want: pkix.Name{CommonName: "svc", Organization: []string{"team"}}},
+		{name: "hex DER value", dn: "CN=#0c03737663", want: pkix.Name{CommonName: "svc"}},
+		{
+			name: "DC keeps IA5String",
+			dn:   "DC=example,DC=com",
+			want: pkix.Name{ExtraNames: []pkix.AttributeTypeAndValue{
+				{Type: oidDC, Value: asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte("example")}},
+				{Type: oidDC, Value: asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte("com")}},
+			}},
+		},
+		{name: "dotted OID of a known attribute", dn: "2.5.4.3=svc", want: pkix.Name{CommonName: "svc"}},
+		{name: "OID prefix", dn: "OID.2.5.4.10=team", want: pkix.Name{Organization: []string{"team"}}},
+		{
+			name: "unknown OID",
+			dn:   "1.2.3.4=hello",
+			want: pkix.Name{ExtraNames: []pkix.AttributeTypeAndValue{{Type: oidCustom, Value: "hello"}}},
+		},
+		{
+			name: "unknown OID with hex DER value",
+			dn:   "1.2.3.4=#130568656c6c6f",
+			want: pkix.Name{ExtraNames: []pkix.AttributeTypeAndValue{{Type: oidCustom, Value: asn1.RawValue{FullBytes: []byte{0x13, 5, 'h', 'e', 'l', 'l', 'o'}}}}},
+		},
+		{name: "missing equal sign", dn: "CN", wantErr: true},
+		{name: "unknown keyword", dn: "XX=svc", wantErr: true},
+		{name: "single arc OID", dn: "1=svc", wantErr: true},
+		{name: "invalid OID", dn: "1.a.3=svc", wantErr: true},
+		{name: "trailing escape", dn: `CN=svc\`, wantErr: true},
+		{name: "invalid escape", dn: `CN=s\vc`, wantErr: true},
+		{name: "unescaped quote", dn: `CN="svc"`, wantErr: true},
+		{name: "invalid hex value", dn: "CN=#zz", wantErr: true},
+		{name: "empty hex value", dn: "CN=#", wantErr: true},
+		{name: "invalid DER value", dn: "CN=#0c05ab", wantErr: true},
+		{name: "invalid UTF-8", dn: `CN=\ff`, wantErr: true},
+		{name: "single valued attribute set twice", dn: "CN=a,CN=b", wantErr: true},
+		{name: "garbage after hex value", dn: "CN=#0c03737663 x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.dn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error %v, want error %v", tt.dn, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.dn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	// The names built from a parsed subject are parsed back to the same name
+	for _, dn := range []string{
+		"CN=svc,OU=prod,O=team,C=FR",
+		`CN=Acme\, Inc.,O=a\+b`,
+	} {
+		t.Run(dn, func(t *testing.T) {
+			name, err := Parse(dn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rdns pkix.RDNSequence
+			der, err := asn1.Marshal(name.ToRDNSequence())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := asn1.Unmarshal(der, &rdns); err != nil {
+				t.Fatal(err)
+			}
+			var decoded pkix.Name
+			decoded.FillFromRDNSequence(&rdns)
+			again, err := Parse(decoded.String())
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", decoded.String(), err)
+			}
+			if again.String() != decoded.String() {
+				t.Errorf("parsed %q as %q", decoded.String(), again.String())
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package types
 
 import (
 	"bytes"
-	"crypto/rsa"
+	"crypto"
 	"crypto/x509"
 )
 
@@ -11,5 +11,7 @@ type Cert struct {
 	CertPem *bytes.Buffer
 	KeyPem  *bytes.Buffer
 	Cert    *x509.Certificate
-	Key     *rsa.PrivateKey
+	Key     crypto.Signer
+	// Chain issuer certificates of Cert, from its direct issuer up to the root CA
+	Chain []*x509.Certificate
 }
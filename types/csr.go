@@ -0,0 +1,15 @@
+package types
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+)
+
+// Csr PKCS#10 certificate request object with request and key in pem and byte format
+type Csr struct {
+	CsrPem *bytes.Buffer
+	KeyPem *bytes.Buffer
+	Csr    *x509.CertificateRequest
+	Key    crypto.Signer
+}
@@ -0,0 +1,23 @@
+package types
+
+// KeyAlgorithm public key algorithm used to generate a private key
+type KeyAlgorithm string
+
+const (
+	// RSA key, size is the modulus length in bits
+	RSA KeyAlgorithm = "rsa"
+	// ECDSA key, size is the NIST curve size (256, 384 or 521)
+	ECDSA KeyAlgorithm = "ecdsa"
+	// Ed25519 key, size is ignored
+	Ed25519 KeyAlgorithm = "ed25519"
+)
+
+// KeyOptions algorithm and size of a private key to generate.
+// A zero Size select the default size of the algorithm.
+type KeyOptions struct {
+	Algorithm KeyAlgorithm
+	Size      int
+}
+
+// DefaultKeyOptions RSA 4096 key options, used when no key option is given
+var DefaultKeyOptions = KeyOptions{Algorithm: RSA, Size: 4096}
@@ -1,14 +1,17 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"math/big"
 	"net"
+	"net/url"
 	"os"
 	"time"
 
@@ -16,61 +19,319 @@ import (
 
 	"github.com/sundae-party/pki/ca"
 	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/serial"
+	"github.com/sundae-party/pki/subject"
+	"github.com/sundae-party/pki/types"
 )
 
-//CreateCertFromCAFile create a new certificate and private key.
+// CertOptions describe a certificate to create from a CA stored on disk
+type CertOptions struct {
+	CAKeyPath  string
+	CACertPath string
+	// CAKeyPassphrase passphrase of the CA private key when it's encrypted
+	CAKeyPassphrase string
+	// CN common name of the certificate, replace the common name of Subject when set
+	CN string
+	// Subject distinguished name of the certificate, see subject.Parse to build it from a RFC 4514 string
+	Subject  pkix.Name
+	Duration time.Duration
+	SansDns  []string
+	SansIp   []net.IP
+	// SansUri URI SANs, like spiffe://trust.domain/workload
+	SansUri []*url.URL
+	// SansEmail email address SANs
+	SansEmail    []string
+	Dest         string
+	CertFileName string
+	KeyFileName  string
+	// Key algorithm and size of the new private key, RSA 4096 if empty
+	Key types.KeyOptions
+	// SerialRegistry path of the CA serial registry, serial.RegistryPath(CACertPath) if empty
+	SerialRegistry string
+	// CAChainPath optional pem file with the issuer chain of the CA when the CA is an intermediate
+	CAChainPath string
+	// ChainFileName if not empty, file name of the full chain written with the cert followed by the CA chain
+	ChainFileName string
+	// Profile key usages and extensions of the certificate, profile.Peer if empty
+	Profile profile.Profile
+	// Inventory directory of the CA inventory, inventory.Path(CACertPath) if empty
+	Inventory string
+	// Requester recorded in the inventory, the current user if empty
+	Requester string
+	// KeyEncryption passphrase encryption of the new private key file, written in clear if no passphrase
+	KeyEncryption keys.Encryption
+	// SKIMethod method used to compute the subject key identifier, keys.DefaultSKIMethod if empty
+	SKIMethod keys.SKIMethod
+}
+
+//CreateCertFromCAFile create a new certificate and RSA 4096 private key.
 // That can be used at the server side for TLS server configuration and
 // at the client side for the mTLS authentication
 func CreateCertFromCAFile(caKeyPath string, caCertPath string, cn string, duration time.Duration, sansDns []string, sansIp []net.IP, dest string, certFileName string, keyFileName string) error {
+	return CreateCert(CertOptions{
+		CAKeyPath:    caKeyPath,
+		CACertPath:   caCertPath,
+		CN:           cn,
+		Duration:     duration,
+		SansDns:      sansDns,
+		SansIp:       sansIp,
+		Dest:         dest,
+		CertFileName: certFileName,
+		KeyFileName:  keyFileName,
+		Key:          types.DefaultKeyOptions,
+	})
+}
+
+// CreateCertFromCAFileWithSubject create a new certificate and RSA 4096 private key like CreateCertFromCAFile,
+// with the subject given as a RFC 4514 distinguished name like "CN=svc,O=team,OU=prod".
+func CreateCertFromCAFileWithSubject(caKeyPath string, caCertPath string, dn string, duration time.Duration, sansDns []string, sansIp []net.IP, dest string, certFileName string, keyFileName string) error {
+	subj, err := subject.Parse(dn)
+	if err != nil {
+		return err
+	}
+	return CreateCert(CertOptions{
+		CAKeyPath:    caKeyPath,
+		CACertPath:   caCertPath,
+		Subject:      subj,
+		Duration:     duration,
+		SansDns:      sansDns,
+		SansIp:       sansIp,
+		Dest:         dest,
+		CertFileName: certFileName,
+		KeyFileName:  keyFileName,
+		Key:          types.DefaultKeyOptions,
+	})
+}
+
+// CreateCert create a new certificate and private key signed by the CA found at opts.CAKeyPath and opts.CACertPath.
+// The CA and the new certificate keys can be of different algorithms.
+func CreateCert(opts CertOptions) error {
 
 	// Load CA
-	caCert, err := LoadCertFromFile(caKeyPath, "", caCertPath)
+	caCert, err := LoadCAFromFile(opts.CAKeyPath, opts.CAKeyPassphrase, opts.CACertPath, opts.CAChainPath)
 	if err != nil {
 		return err
 	}
 
-	// Build new cert subject with CN
-	certSubj := &pkix.Name{
-		CommonName: cn,
+	// Build new cert subject, the CN option has priority over the subject common name
+	certSubj := opts.Subject
+	if opts.CN != "" {
+		certSubj.CommonName = opts.CN
+	}
+	if certSubj.CommonName == "" && len(opts.SansDns)+len(opts.SansIp)+len(opts.SansUri)+len(opts.SansEmail) == 0 {
+		return errors.New("a common name or a SAN is required to identify the certificate")
 	}
 
 	// Create CSR
-	csrSrv := csr.CreateCSR(*certSubj, sansDns, sansIp, time.Now(), duration)
+	csrSrv, err := csr.CreateCSRWithOptions(csr.Options{
+		Subject:   certSubj,
+		SansDns:   opts.SansDns,
+		SansIP:    opts.SansIp,
+		SansURI:   opts.SansUri,
+		SansEmail: opts.SansEmail,
+		Start:     time.Now(),
+		Duration:  opts.Duration,
+		Key:       opts.Key,
+		Profile:   opts.Profile,
+		SKIMethod: opts.SKIMethod,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Get a serial number never issued by this CA
+	csrSrv.Cert.SerialNumber, err = nextSerial(opts.SerialRegistry, opts.CACertPath)
+	if err != nil {
+		return err
+	}
+
 	// Sign CSR with given CA
-	cert := ca.Sign(caCert, csrSrv)
+	cert, err := ca.SignCert(caCert, csrSrv)
+	if err != nil {
+		return err
+	}
+
+	// Encrypt the private key
+	cert.KeyPem, err = opts.KeyEncryption.EncodePEM(cert.Key)
+	if err != nil {
+		return err
+	}
+
+	files, err := writeCertFiles(cert, opts.Dest, opts.CertFileName, opts.KeyFileName, opts.ChainFileName)
+	if err != nil {
+		return err
+	}
+
+	// Record the new cert in the CA inventory
+	p := opts.Profile.Name
+	if p == "" {
+		p = profile.Peer.Name
+	}
+	return RecordIssuance(opts.Inventory, opts.CACertPath, cert.Cert, p, opts.Requester, files)
+}
+
+// SignCSROptions describe a PKCS#10 certificate request to sign with a CA stored on disk
+type SignCSROptions struct {
+	CAKeyPath  string
+	CACertPath string
+	// CAKeyPassphrase passphrase of the CA private key when it's encrypted
+	CAKeyPassphrase string
+	CSRPath         string
+	Duration        time.Duration
+	Profile         profile.Profile
+	Dest            string
+	CertFileName    string
+	// SerialRegistry path of the CA serial registry, serial.RegistryPath(CACertPath) if empty
+	SerialRegistry string
+	// CAChainPath optional pem file with the issuer chain of the CA when the CA is an intermediate
+	CAChainPath string
+	// ChainFileName if not empty, file name of the full chain written with the cert followed by the CA chain
+	ChainFileName string
+	// Inventory directory of the CA inventory, inventory.Path(CACertPath) if empty
+	Inventory string
+	// Requester recorded in the inventory, the current user if empty
+	Requester string
+	// SKIMethod method used to compute the subject key identifier, keys.DefaultSKIMethod if empty
+	SKIMethod keys.SKIMethod
+}
+
+// SignCSRFromCAFile sign the PKCS#10 certificate request found at opts.CSRPath with the CA found at
+// opts.CAKeyPath and opts.CACertPath. Only the certificate is written, the private key stay with the requester.
+func SignCSRFromCAFile(opts SignCSROptions) error {
+
+	// Load CA
+	caCert, err := LoadCAFromFile(opts.CAKeyPath, opts.CAKeyPassphrase, opts.CACertPath, opts.CAChainPath)
+	if err != nil {
+		return err
+	}
+
+	// Load the certificate request
+	req, err := LoadCSRFromFile(opts.CSRPath)
+	if err != nil {
+		return err
+	}
+
+	// Get a serial number never issued by this CA
+	sn, err := nextSerial(opts.SerialRegistry, opts.CACertPath)
+	if err != nil {
+		return err
+	}
+
+	cert, err := ca.SignCSR(caCert, req, ca.SignOptions{
+		Profile:      opts.Profile,
+		Start:        time.Now(),
+		Duration:     opts.Duration,
+		SerialNumber: sn,
+		SKIMethod:    opts.SKIMethod,
+	})
+	if err != nil {
+		return err
+	}
+
+	files, err := writeCertFiles(cert, opts.Dest, opts.CertFileName, "", opts.ChainFileName)
+	if err != nil {
+		return err
+	}
+
+	// Record the new cert in the CA inventory
+	return RecordIssuance(opts.Inventory, opts.CACertPath, cert.Cert, opts.Profile.Name, opts.Requester, files)
+}
+
+// LoadCAFromFile load a CA cert and key, decrypted with keyPassphrase if encrypted,
+// and the CA issuer chain from chainPath if not empty, see LoadCertWithChainFromFile.
+func LoadCAFromFile(keyPath string, keyPassphrase string, certPath string, chainPath string) (*types.Cert, error) {
+	return LoadCertWithChainFromFile(keyPath, keyPassphrase, certPath, chainPath)
+}
+
+// nextSerial get a new serial from the registry at registryPath, or next to the CA cert if empty
+func nextSerial(registryPath string, caCertPath string) (*big.Int, error) {
+	if registryPath == "" {
+		registryPath = serial.RegistryPath(caCertPath)
+	}
+	registry, err := serial.OpenRegistry(registryPath)
+	if err != nil {
+		return nil, err
+	}
+	return registry.Next()
+}
+
+// CertFiles paths of the files written for a certificate, empty if not written
+type CertFiles struct {
+	CertPath  string
+	KeyPath   string
+	ChainPath string
+}
+
+// writeCertFiles write the cert, key and full chain files in dest.
+// The key and chain files are not written if their file name is empty.
+func writeCertFiles(cert *types.Cert, dest string, certFileName string, keyFileName string, chainFileName string) (CertFiles, error) {
+	var files CertFiles
 
 	// Create destination folder
 	if _, err := os.Stat(dest); os.IsNotExist(err) {
 		err := os.Mkdir(dest, 0700)
 		if err != nil {
-			return err
+			return files, err
 		}
 	}
 
-	// Build cert path and key path
-	certPath := fmt.Sprintf("%s/%s", dest, certFileName)
-	keyPath := fmt.Sprintf("%s/%s", dest, keyFileName)
-
-	// Write Cert and Key files
-	err = ioutil.WriteFile(certPath, cert.CertPem.Bytes(), 0600)
+	// Write Cert file
+	files.CertPath = fmt.Sprintf("%s/%s", dest, certFileName)
+	err := ioutil.WriteFile(files.CertPath, cert.CertPem.Bytes(), 0600)
 	if err != nil {
-		return err
+		return files, err
 	}
-	err = ioutil.WriteFile(keyPath, cert.KeyPem.Bytes(), 0600)
-	if err != nil {
-		return err
+
+	// Write Key file
+	if keyFileName != "" {
+		files.KeyPath = fmt.Sprintf("%s/%s", dest, keyFileName)
+		err = ioutil.WriteFile(files.KeyPath, cert.KeyPem.Bytes(), 0600)
+		if err != nil {
+			return files, err
+		}
+	}
+
+	// Write full chain file
+	if chainFileName != "" {
+		files.ChainPath = fmt.Sprintf("%s/%s", dest, chainFileName)
+		chainPem := EncodeCertsPem(append([]*x509.Certificate{cert.Cert}, cert.Chain...))
+		err = ioutil.WriteFile(files.ChainPath, chainPem.Bytes(), 0600)
+		if err != nil {
+			return files, err
+		}
 	}
 
-	return nil
+	return files, nil
+}
+
+// EncodeCertsPem get the certificates in pem format, in the given order
+func EncodeCertsPem(certs []*x509.Certificate) *bytes.Buffer {
+	certsPem := new(bytes.Buffer)
+	for _, cert := range certs {
+		pem.Encode(certsPem, &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: cert.Raw,
+		})
+	}
+	return certsPem
 }
 
 // BuildServerTlsConf create a tlsConfig object of type *tls.Config configured to be used in the server side.
 // If one or more CA certificates are provided through CAPaths,
 // mTLS configuration will be enabled and this certificates will be used to validate the client certificates.
+// The files are loaded once, see BuildReloadingServerTlsConf to serve the rotated files without a restart.
 func BuildServerTlsConf(CAPaths []string, certPath string, keyPath string) (tlsConfig *tls.Config, err error) {
+	return BuildServerTlsConfWithPassphrase(CAPaths, certPath, keyPath, "")
+}
+
+// BuildServerTlsConfWithPassphrase create a tlsConfig like BuildServerTlsConf, the private key is decrypted with
+// keyPassphrase when it's an encrypted PKCS#8 key
+func BuildServerTlsConfWithPassphrase(CAPaths []string, certPath string, keyPath string, keyPassphrase string) (tlsConfig *tls.Config, err error) {
 
 	// SSL server configuration
-	serverCert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	serverCert, err := loadTLSCertificate(certPath, keyPath, keyPassphrase)
 	if err != nil {
 		return nil, err
 	}
@@ -86,9 +347,11 @@ func BuildServerTlsConf(CAPaths []string, certPath string, keyPath string) (tlsC
 		for _, caPath := range CAPaths {
 			caCert, err := ioutil.ReadFile(caPath)
 			if err != nil {
-				log.Fatal(err)
+				return nil, err
+			}
+			if !caCertPool.AppendCertsFromPEM(caCert) {
+				return nil, fmt.Errorf("%s: no CA certificate found in pem file", caPath)
 			}
-			caCertPool.AppendCertsFromPEM(caCert)
 		}
 
 		// Create the server TLS Config with the CA pool and enable Client certificate validation.
@@ -101,9 +364,15 @@ func BuildServerTlsConf(CAPaths []string, certPath string, keyPath string) (tlsC
 
 // LoadKeyPair create a tlsConfig object of type credentials.TransportCredentials configured to be used in the gRPC client side with mTLS enabled.
 func LoadKeyPair(certPath string, keyPath string, caPath string) (clientTLSConfig credentials.TransportCredentials, err error) {
+	return LoadKeyPairWithPassphrase(certPath, keyPath, "", caPath)
+}
+
+// LoadKeyPairWithPassphrase create the gRPC client credentials like LoadKeyPair, the private key is decrypted with
+// keyPassphrase when it's an encrypted PKCS#8 key
+func LoadKeyPairWithPassphrase(certPath string, keyPath string, keyPassphrase string, caPath string) (clientTLSConfig credentials.TransportCredentials, err error) {
 
 	// Load client cert & key
-	certificate, err := tls.LoadX509KeyPair(certPath, keyPath)
+	certificate, err := loadTLSCertificate(certPath, keyPath, keyPassphrase)
 	if err != nil {
 		return nil, err
 	}
@@ -127,3 +396,25 @@ func LoadKeyPair(certPath string, keyPath string, caPath string) (clientTLSConfi
 
 	return credentials.NewTLS(tlsConfig), nil
 }
+
+// loadTLSCertificate load the cert, its chain and its private key in any format read by keys.Parse,
+// the key must match the cert
+func loadTLSCertificate(certPath string, keyPath string, keyPassphrase string) (tls.Certificate, error) {
+	certObj, err := LoadCertFromFile(keyPath, keyPassphrase, certPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tlsCertificate(certObj), nil
+}
+
+// tlsCertificate get the cert, its chain and its private key as a tls.Certificate
+func tlsCertificate(certObj *types.Cert) tls.Certificate {
+	cert := tls.Certificate{
+		PrivateKey: certObj.Key,
+		Leaf:       certObj.Cert,
+	}
+	for _, c := range append([]*x509.Certificate{certObj.Cert}, certObj.Chain...) {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestBuildServerTlsConfEncryptedKey(t *testing.T) {
+	dir := t.TempDir()
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+		Subject:  pkix.Name{CommonName: "localhost"},
+		SansDns:  []string{"localhost"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+		Profile:  profile.Peer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ca.SignCert(root, leafCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPath := filepath.Join(dir, "ca.pem")
+	certPath := filepath.Join(dir, "srv.pem")
+	if err := ioutil.WriteFile(caPath, root.CertPem.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certPath, leaf.CertPem.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		encryption keys.Encryption
+		passphrase string
+		err        error
+	}{
+		{"clear key", keys.Encryption{}, "", nil},
+		{"scrypt encrypted key", keys.Encryption{Passphrase: []byte("secret"), KDF: keys.Scrypt}, "secret", nil},
+		{"pbkdf2 encrypted key", keys.Encryption{Passphrase: []byte("secret"), KDF: keys.PBKDF2}, "secret", nil},
+		{"missing passphrase", keys.Encryption{Passphrase: []byte("secret")}, "", keys.ErrPassphraseRequired},
+		{"wrong passphrase", keys.Encryption{Passphrase: []byte("secret")}, "wrong", keys.ErrWrongPassphrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyPem, err := tt.encryption.EncodePEM(leaf.Key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyPath := filepath.Join(dir, "srv.key")
+			if err := ioutil.WriteFile(keyPath, keyPem.Bytes(), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			serverConf, err := BuildServerTlsConfWithPassphrase([]string{caPath}, certPath, keyPath, tt.passphrase)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("server config: got error %v, want %v", err, tt.err)
+			}
+			if _, err := LoadKeyPairWithPassphrase(certPath, keyPath, tt.passphrase, caPath); !errors.Is(err, tt.err) {
+				t.Fatalf("client credentials: got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			// The loaded key pair is usable for a mTLS handshake
+			roots := x509.NewCertPool()
+			roots.AddCert(root.Cert)
+			clientConf := &tls.Config{
+				Certificates: serverConf.Certificates,
+				RootCAs:      roots,
+				ServerName:   "localhost",
+			}
+			serverConn, clientConn := net.Pipe()
+			defer serverConn.Close()
+			defer clientConn.Close()
+			errs := make(chan error, 1)
+			go func() {
+				errs <- tls.Server(serverConn, serverConf).Handshake()
+			}()
+			if err := tls.Client(clientConn, clientConf).Handshake(); err != nil {
+				t.Fatalf("client handshake: %v", err)
+			}
+			if err := <-errs; err != nil {
+				t.Fatalf("server handshake: %v", err)
+			}
+		})
+	}
+}
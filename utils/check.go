@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sundae-party/pki/serial"
+)
+
+// ExpiryStatus expiry status of a checked certificate
+type ExpiryStatus string
+
+const (
+	// ExpiryOk certificate valid for longer than the threshold
+	ExpiryOk ExpiryStatus = "ok"
+	// ExpiryExpiring certificate expiring within the threshold
+	ExpiryExpiring ExpiryStatus = "expiring"
+	// ExpiryExpired certificate already expired
+	ExpiryExpired ExpiryStatus = "expired"
+	// ExpiryError file which can't be read or parsed
+	ExpiryError ExpiryStatus = "error"
+)
+
+// ExpiryCheck expiry check result of a certificate found in a pem or der file
+type ExpiryCheck struct {
+	Path string `json:"path"`
+	// Index of the certificate in the file, chains and bundles have more than one certificate
+	Index     int          `json:"index"`
+	Subject   string       `json:"subject,omitempty"`
+	Serial    string       `json:"serial,omitempty"`
+	NotAfter  time.Time    `json:"notAfter,omitempty"`
+	ExpiresIn string       `json:"expiresIn,omitempty"`
+	Status    ExpiryStatus `json:"status"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// CheckExpiry parse every certificate of the files, directories or glob patterns in paths and get their expiry status.
+// Directories are walked recursively and their files without certificate are skipped, no private key is needed.
+func CheckExpiry(paths []string, threshold time.Duration, now time.Time) ([]ExpiryCheck, error) {
+	checks := []ExpiryCheck{}
+	for _, pattern := range paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			checks = append(checks, ExpiryCheck{Path: pattern, Status: ExpiryError, Error: "no such file or directory"})
+			continue
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				checks = append(checks, ExpiryCheck{Path: match, Status: ExpiryError, Error: err.Error()})
+				continue
+			}
+			if !info.IsDir() {
+				checks = append(checks, checkFile(match, threshold, now, false)...)
+				continue
+			}
+			err = filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					checks = append(checks, ExpiryCheck{Path: path, Status: ExpiryError, Error: err.Error()})
+					return nil
+				}
+				if info.Mode().IsRegular() {
+					checks = append(checks, checkFile(path, threshold, now, true)...)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return checks, nil
+}
+
+// checkFile check all the certificates of a pem or der file, skipFileWithoutCert ignore files without certificate
+func checkFile(path string, threshold time.Duration, now time.Time, skipFileWithoutCert bool) []ExpiryCheck {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []ExpiryCheck{{Path: path, Status: ExpiryError, Error: err.Error()}}
+	}
+	certs, err := ParseChain(data)
+	if err != nil {
+		if skipFileWithoutCert && errors.Is(err, ErrNoCertificateFound) {
+			return nil
+		}
+		return []ExpiryCheck{{Path: path, Status: ExpiryError, Error: err.Error()}}
+	}
+
+	var checks []ExpiryCheck
+	for i, cert := range certs {
+		check := ExpiryCheck{
+			Path:      path,
+			Index:     i,
+			Subject:   cert.Subject.String(),
+			Serial:    serial.Format(cert.SerialNumber),
+			NotAfter:  cert.NotAfter,
+			ExpiresIn: cert.NotAfter.Sub(now).Round(time.Second).String(),
+		}
+		switch {
+		case now.After(cert.NotAfter):
+			check.Status = ExpiryExpired
+		case now.Add(threshold).After(cert.NotAfter):
+			check.Status = ExpiryExpiring
+		default:
+			check.Status = ExpiryOk
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// SortByExpiry sort the checks by NotAfter date, errors first
+func SortByExpiry(checks []ExpiryCheck) {
+	sort.SliceStable(checks, func(a, b int) bool {
+		if checks[a].Status == ExpiryError || checks[b].Status == ExpiryError {
+			return checks[a].Status == ExpiryError && checks[b].Status != ExpiryError
+		}
+		return checks[a].NotAfter.Before(checks[b].NotAfter)
+	})
+}
+
+// Exit codes of the expiry checks, 1 is left to the command errors
+const (
+	ExitExpiring   = 2
+	ExitExpired    = 3
+	ExitUnreadable = 4
+)
+
+// ExpiryExitCode get the monitoring exit code of the checks:
+// 0 all ok, 2 some certificates expiring, 3 some certificates expired, 4 some files can't be checked
+func ExpiryExitCode(checks []ExpiryCheck) int {
+	code := 0
+	for _, check := range checks {
+		c := 0
+		switch check.Status {
+		case ExpiryExpiring:
+			c = ExitExpiring
+		case ExpiryExpired:
+			c = ExitExpired
+		case ExpiryError:
+			c = ExitUnreadable
+		}
+		if c > code {
+			code = c
+		}
+	}
+	return code
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestCheckExpiryDER(t *testing.T) {
+	now := time.Now()
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "test root"},
+		Start:    now.Add(-time.Minute),
+		Duration: 24 * time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	derPath := filepath.Join(dir, "ca.der")
+	if err := ioutil.WriteFile(derPath, root.Cert.Raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		paths     []string
+		threshold time.Duration
+		want      ExpiryStatus
+		code      int
+	}{
+		{"ok", []string{derPath}, time.Hour, ExpiryOk, 0},
+		{"expiring", []string{derPath}, 48 * time.Hour, ExpiryExpiring, ExitExpiring},
+		{"directory skips files without certificate", []string{dir}, time.Hour, ExpiryOk, 0},
+		{"file without certificate", []string{filepath.Join(dir, "notes.txt")}, time.Hour, ExpiryError, ExitUnreadable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := CheckExpiry(tt.paths, tt.threshold, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(checks) != 1 || checks[0].Status != tt.want {
+				t.Fatalf("got checks %+v, want one %s check", checks, tt.want)
+			}
+			if code := ExpiryExitCode(checks); code != tt.code {
+				t.Errorf("got exit code %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestCheckExpiryEmptyDirectory(t *testing.T) {
+	checks, err := CheckExpiry([]string{t.TempDir()}, time.Hour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An empty result is encoded as an empty json array, not null
+	data, err := json.Marshal(checks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("checks encoded as %s, want []", data)
+	}
+	if code := ExpiryExitCode(checks); code != 0 {
+		t.Errorf("got exit code %d, want 0", code)
+	}
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/serial"
+)
+
+// CertInfo detailed view of a certificate
+type CertInfo struct {
+	Serial             string    `json:"serial" yaml:"serial"`
+	Version            int       `json:"version" yaml:"version"`
+	SignatureAlgorithm string    `json:"signatureAlgorithm" yaml:"signatureAlgorithm"`
+	Issuer             string    `json:"issuer" yaml:"issuer"`
+	Subject            string    `json:"subject" yaml:"subject"`
+	SubjectFields      NameInfo  `json:"subjectFields" yaml:"subjectFields"`
+	NotBefore          time.Time `json:"notBefore" yaml:"notBefore"`
+	NotAfter           time.Time `json:"notAfter" yaml:"notAfter"`
+	DNSNames           []string  `json:"dnsNames,omitempty" yaml:"dnsNames,omitempty"`
+	IPAddresses        []string  `json:"ipAddresses,omitempty" yaml:"ipAddresses,omitempty"`
+	URIs               []string  `json:"uris,omitempty" yaml:"uris,omitempty"`
+	EmailAddresses     []string  `json:"emailAddresses,omitempty" yaml:"emailAddresses,omitempty"`
+	KeyAlgorithm       string    `json:"keyAlgorithm" yaml:"keyAlgorithm"`
+	KeyUsage           []string  `json:"keyUsage,omitempty" yaml:"keyUsage,omitempty"`
+	ExtKeyUsage        []string  `json:"extKeyUsage,omitempty" yaml:"extKeyUsage,omitempty"`
+	IsCA               bool      `json:"isCA" yaml:"isCA"`
+	// MaxPathLen path length constraint of a CA, -1 if unlimited
+	MaxPathLen   *int   `json:"maxPathLen,omitempty" yaml:"maxPathLen,omitempty"`
+	SubjectKeyID string `json:"subjectKeyId,omitempty" yaml:"subjectKeyId,omitempty"`
+	// SubjectKeyIDMethod method used to compute the subject key identifier from the public key, empty if unknown
+	SubjectKeyIDMethod    string   `json:"subjectKeyIdMethod,omitempty" yaml:"subjectKeyIdMethod,omitempty"`
+	AuthorityKeyID        string   `json:"authorityKeyId,omitempty" yaml:"authorityKeyId,omitempty"`
+	CRLDistributionPoints []string `json:"crlDistributionPoints,omitempty" yaml:"crlDistributionPoints,omitempty"`
+	OCSPServers           []string `json:"ocspServers,omitempty" yaml:"ocspServers,omitempty"`
+	IssuingCertificateURL []string `json:"issuingCertificateUrl,omitempty" yaml:"issuingCertificateUrl,omitempty"`
+	FingerprintSHA1       string   `json:"fingerprintSha1" yaml:"fingerprintSha1"`
+	FingerprintSHA256     string   `json:"fingerprintSha256" yaml:"fingerprintSha256"`
+	// SPKIPin base64 SHA-256 of the subject public key info, as used by HPKP and certificate pinning
+	SPKIPin string `json:"spkiPin" yaml:"spkiPin"`
+	// KeyMatch set when the certificate is read with its private key
+	KeyMatch *bool `json:"keyMatch,omitempty" yaml:"keyMatch,omitempty"`
+}
+
+// NameInfo attributes of a distinguished name
+type NameInfo struct {
+	CommonName         string   `json:"commonName,omitempty" yaml:"commonName,omitempty"`
+	Organization       []string `json:"organization,omitempty" yaml:"organization,omitempty"`
+	OrganizationalUnit []string `json:"organizationalUnit,omitempty" yaml:"organizationalUnit,omitempty"`
+	Country            []string `json:"country,omitempty" yaml:"country,omitempty"`
+	Province           []string `json:"province,omitempty" yaml:"province,omitempty"`
+	Locality           []string `json:"locality,omitempty" yaml:"locality,omitempty"`
+	StreetAddress      []string `json:"streetAddress,omitempty" yaml:"streetAddress,omitempty"`
+	PostalCode         []string `json:"postalCode,omitempty" yaml:"postalCode,omitempty"`
+	SerialNumber       string   `json:"serialNumber,omitempty" yaml:"serialNumber,omitempty"`
+}
+
+// DescribeCert get the detailed view of a certificate
+func DescribeCert(cert *x509.Certificate) CertInfo {
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+	pin := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	info := CertInfo{
+		Serial:                serial.Format(cert.SerialNumber),
+		Version:               cert.Version,
+		SignatureAlgorithm:    cert.SignatureAlgorithm.String(),
+		Issuer:                cert.Issuer.String(),
+		Subject:               cert.Subject.String(),
+		SubjectFields:         describeName(cert.Subject),
+		NotBefore:             cert.NotBefore,
+		NotAfter:              cert.NotAfter,
+		DNSNames:              cert.DNSNames,
+		EmailAddresses:        cert.EmailAddresses,
+		KeyAlgorithm:          keys.Describe(cert.PublicKey),
+		KeyUsage:              profile.KeyUsageNames(cert.KeyUsage),
+		ExtKeyUsage:           profile.ExtKeyUsageNames(cert.ExtKeyUsage, cert.UnknownExtKeyUsage),
+		IsCA:                  cert.IsCA,
+		SubjectKeyID:          hexColon(cert.SubjectKeyId),
+		SubjectKeyIDMethod:    string(keys.SKIMethodOf(cert)),
+		AuthorityKeyID:        hexColon(cert.AuthorityKeyId),
+		CRLDistributionPoints: cert.CRLDistributionPoints,
+		OCSPServers:           cert.OCSPServer,
+		IssuingCertificateURL: cert.IssuingCertificateURL,
+		FingerprintSHA1:       hexColon(sha1Sum[:]),
+		FingerprintSHA256:     hexColon(sha256Sum[:]),
+		SPKIPin:               base64.StdEncoding.EncodeToString(pin[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+	if cert.IsCA {
+		maxPathLen := cert.MaxPathLen
+		if maxPathLen == 0 && !cert.MaxPathLenZero {
+			maxPathLen = -1
+		}
+		info.MaxPathLen = &maxPathLen
+	}
+	return info
+}
+
+func describeName(name pkix.Name) NameInfo {
+	return NameInfo{
+		CommonName:         name.CommonName,
+		Organization:       name.Organization,
+		OrganizationalUnit: name.OrganizationalUnit,
+		Country:            name.Country,
+		Province:           name.Province,
+		Locality:           name.Locality,
+		StreetAddress:      name.StreetAddress,
+		PostalCode:         name.PostalCode,
+		SerialNumber:       name.SerialNumber,
+	}
+}
+
+// hexColon format bytes in upper case hexadecimal separated by colons, like openssl
+func hexColon(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"crypto/x509"
+	"os/user"
+	"path/filepath"
+
+	"github.com/sundae-party/pki/inventory"
+)
+
+// RecordIssuance add a new issued certificate to the inventory at inventoryPath, or next to the CA cert if empty.
+// The requester is the current user if empty, the files paths are recorded as absolute paths.
+func RecordIssuance(inventoryPath string, caCertPath string, cert *x509.Certificate, profileName string, requester string, files CertFiles) error {
+	if inventoryPath == "" {
+		inventoryPath = inventory.Path(caCertPath)
+	}
+	inv, err := inventory.Open(inventoryPath)
+	if err != nil {
+		return err
+	}
+
+	r := inventory.NewRecord(cert)
+	r.Profile = profileName
+	r.Requester = requester
+	if r.Requester == "" {
+		if u, err := user.Current(); err == nil {
+			r.Requester = u.Username
+		}
+	}
+	r.CertPath = absPath(files.CertPath)
+	r.KeyPath = absPath(files.KeyPath)
+	r.ChainPath = absPath(files.ChainPath)
+
+	return inv.Add(r)
+}
+
+// absPath get the absolute path, empty paths stay empty
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
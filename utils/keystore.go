@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/sundae-party/pki/store"
+)
+
+// KeyStoreExportOptions describe a cert stored on disk to export in a Java key store
+type KeyStoreExportOptions struct {
+	CertPath string
+	KeyPath  string
+	// KeyPassphrase passphrase of the private key file when it's encrypted
+	KeyPassphrase string
+	// ChainPath optional pem file with the CA chain, the chain following the cert in the cert file is used otherwise
+	ChainPath string
+	// OutPath path of the key store file
+	OutPath string
+	// Alias of the key entry, the lower case common name of the cert if empty
+	Alias         string
+	StorePassword string
+	// KeyPassword password of the key entry of a JKS store, the store password if empty
+	KeyPassword string
+	Type        store.KeystoreType
+}
+
+// ExportKeyStoreFile write a Java key store with the cert, its private key and its CA chain
+func ExportKeyStoreFile(opts KeyStoreExportOptions) error {
+	cert, err := LoadCertWithChainFromFile(opts.KeyPath, opts.KeyPassphrase, opts.CertPath, opts.ChainPath)
+	if err != nil {
+		return err
+	}
+	ks, err := store.EncodeKeyStore(cert, opts.Alias, opts.StorePassword, opts.KeyPassword, opts.Type)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(opts.OutPath, ks, 0600)
+}
+
+// TrustStoreExportOptions describe CA certs stored on disk to export in a Java trust store
+type TrustStoreExportOptions struct {
+	// CertPaths pem or der files with one or more CA certs
+	CertPaths []string
+	// Aliases of the unique certs in file order, the lower case common name of the cert is used for the missing aliases
+	Aliases []string
+	// OutPath path of the trust store file
+	OutPath  string
+	Password string
+	Type     store.KeystoreType
+}
+
+// ExportTrustStoreFile write a Java trust store with a trusted certificate entry per cert found in the cert files.
+// The certs found in several files are only added once.
+func ExportTrustStoreFile(opts TrustStoreExportOptions) error {
+	var trusted []*x509.Certificate
+	var entries []store.TrustedEntry
+	for _, certPath := range opts.CertPaths {
+		certs, err := LoadChainFromFile(certPath)
+		if err != nil {
+			return err
+		}
+		for _, cert := range certs {
+			if !containsCert(trusted, cert) {
+				trusted = append(trusted, cert)
+				entries = append(entries, store.TrustedEntry{Cert: cert})
+			}
+		}
+	}
+	if len(opts.Aliases) > len(entries) {
+		return fmt.Errorf("%d aliases given for %d certificates", len(opts.Aliases), len(entries))
+	}
+	for i, alias := range opts.Aliases {
+		entries[i].Alias = alias
+	}
+
+	ts, err := store.EncodeTrustStore(entries, opts.Password, opts.Type)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(opts.OutPath, ts, 0600)
+}
@@ -2,72 +2,198 @@ package utils
 
 import (
 	"bytes"
-	"crypto/rsa"
+	"crypto"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io/ioutil"
 
+	"github.com/sundae-party/pki/keys"
 	"github.com/sundae-party/pki/types"
 )
 
+// LoadCertFromFile load a certificate and its private key, decrypted with rsaPrivateKeyPassword if encrypted.
+// The cert file can be in pem or der format. When it holds several certificates, the first one is the certificate
+// and the next ones are its issuer chain. The private key must match the certificate public key.
 func LoadCertFromFile(keyPath string, rsaPrivateKeyPassword string, certPath string) (certObj *types.Cert, err error) {
 
 	// open cert
-	certBytes, err := ioutil.ReadFile(certPath)
+	certs, err := LoadChainFromFile(certPath)
 	if err != nil {
 		return nil, err
 	}
-	certBlock, _ := pem.Decode(certBytes)
-	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	cert := certs[0]
 
 	// Gen cert pem
-	certPEM := new(bytes.Buffer)
-	pem.Encode(certPEM, certBlock)
+	certPEM := EncodeCertsPem([]*x509.Certificate{cert})
 
-	// Get RSA key from file
-	key, certPrivKeyPEM, err := loadRsa(keyPath, rsaPrivateKeyPassword)
+	// Get private key from file
+	key, certPrivKeyPEM, err := loadKey(keyPath, rsaPrivateKeyPassword)
 	if err != nil {
 		return nil, err
 	}
+	if err := keys.CheckMatch(key, cert.PublicKey); err != nil {
+		return nil, fmt.Errorf("%s and %s: %w", keyPath, certPath, err)
+	}
 
 	certObj = &types.Cert{
 		CertPem: certPEM,
 		KeyPem:  certPrivKeyPEM,
 		Cert:    cert,
 		Key:     key,
+		Chain:   certs[1:],
 	}
 
 	return certObj, nil
 }
 
-func loadRsa(keyPath string, rsaPrivateKeyPassword string) (*rsa.PrivateKey, *bytes.Buffer, error) {
+// LoadCertWithChainFromFile load a cert and key, decrypted with keyPassphrase if encrypted,
+// and the issuer chain from chainPath if not empty.
+// The chain can also be given after the cert in the cert file.
+// The cert itself and the certificates already in the chain are skipped if present in the chain file.
+func LoadCertWithChainFromFile(keyPath string, keyPassphrase string, certPath string, chainPath string) (*types.Cert, error) {
+	certObj, err := LoadCertFromFile(keyPath, keyPassphrase, certPath)
+	if err != nil {
+		return nil, err
+	}
+	if chainPath == "" {
+		return certObj, nil
+	}
+
+	certs, err := LoadChainFromFile(chainPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range certs {
+		if !containsCert(append([]*x509.Certificate{certObj.Cert}, certObj.Chain...), cert) {
+			certObj.Chain = append(certObj.Chain, cert)
+		}
+	}
+	return certObj, nil
+}
+
+// containsCert check if cert is in certs
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadKeyFromFile load a private key in pem or der format, decrypted with keyPassphrase if encrypted
+func LoadKeyFromFile(keyPath string, keyPassphrase string) (crypto.Signer, error) {
+	key, _, err := loadKey(keyPath, keyPassphrase)
+	return key, err
+}
+
+// loadKey load a RSA (PKCS#1), EC (SEC1) or PKCS#8 private key from a pem or der file.
+// Encrypted PKCS#8 keys and legacy encrypted pem keys are decrypted with the password.
+// The returned pem is the decrypted key.
+func loadKey(keyPath string, rsaPrivateKeyPassword string) (crypto.Signer, *bytes.Buffer, error) {
 	// open key file
 	keyBytes, err := ioutil.ReadFile(keyPath)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	keyBlock, _ := pem.Decode(keyBytes)
-	if keyBlock.Type != "RSA PRIVATE KEY" {
-		return nil, nil, errors.New("RSA private key is of the wrong type")
+	key, err := keys.Parse(keyBytes, []byte(rsaPrivateKeyPassword))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+
+	pem, err := keys.EncodePEM(key)
+	if err != nil {
+		return nil, nil, err
 	}
+	return key, pem, nil
+}
 
-	var privPemBytes []byte
-	if rsaPrivateKeyPassword != "" {
-		privPemBytes, err = x509.DecryptPEMBlock(keyBlock, []byte(rsaPrivateKeyPassword))
+// LoadChainFromFile load all the certificates of a pem or der file, in file order.
+// Pem blocks which are not certificates are skipped.
+func LoadChainFromFile(certPath string) ([]*x509.Certificate, error) {
+	certBytes, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := ParseChain(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", certPath, err)
+	}
+	return certs, nil
+}
+
+// ErrNoCertificateFound returned when the data holds no certificate
+var ErrNoCertificateFound = errors.New("no certificate found")
+
+// ParseChain parse all the certificates of pem or der data, in data order.
+// Pem blocks which are not certificates are skipped.
+func ParseChain(certBytes []byte) ([]*x509.Certificate, error) {
+
+	// DER data with one or several concatenated certificates
+	if block, _ := pem.Decode(certBytes); block == nil {
+		certs, err := x509.ParseCertificates(certBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w in pem or der format: %v", ErrNoCertificateFound, err)
+		}
+		if len(certs) == 0 {
+			return nil, ErrNoCertificateFound
+		}
+		return certs, nil
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, certBytes = pem.Decode(certBytes)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
 		if err != nil {
-			return nil, nil, err
+			return nil, fmt.Errorf("invalid certificate #%d: %v", len(certs)+1, err)
 		}
-	} else {
-		privPemBytes = keyBlock.Bytes
+		certs = append(certs, cert)
 	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w in pem data", ErrNoCertificateFound)
+	}
+	return certs, nil
+}
 
-	key, err := x509.ParsePKCS1PrivateKey(privPemBytes)
+// LoadCSRFromFile load a PKCS#10 certificate request from a pem or der file
+func LoadCSRFromFile(csrPath string) (*x509.CertificateRequest, error) {
+	csrBytes, err := ioutil.ReadFile(csrPath)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	pem := bytes.NewBuffer(privPemBytes)
-	return key, pem, nil
+	// DER certificate request
+	if block, _ := pem.Decode(csrBytes); block == nil {
+		req, err := x509.ParseCertificateRequest(csrBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: no certificate request found in pem or der format: %v", csrPath, err)
+		}
+		return req, nil
+	}
+
+	for {
+		var block *pem.Block
+		block, csrBytes = pem.Decode(csrBytes)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no certificate request found in pem file", csrPath)
+		}
+		if block.Type == "CERTIFICATE REQUEST" || block.Type == "NEW CERTIFICATE REQUEST" {
+			req, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid certificate request: %v", csrPath, err)
+			}
+			return req, nil
+		}
+	}
 }
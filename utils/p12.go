@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"io/ioutil"
+
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/store"
+	"github.com/sundae-party/pki/types"
+)
+
+// P12ExportOptions describe a cert stored on disk to bundle in a PKCS#12 file
+type P12ExportOptions struct {
+	CertPath string
+	KeyPath  string
+	// KeyPassphrase passphrase of the private key when it's encrypted
+	KeyPassphrase string
+	// ChainPath optional pem file with the CA chain, the chain following the cert in the cert file is used otherwise
+	ChainPath string
+	// OutPath path of the PKCS#12 file
+	OutPath string
+	// Password protecting the PKCS#12 file
+	Password   string
+	Encryption store.P12Encryption
+}
+
+// ExportP12File bundle the cert, its private key and its CA chain in a password protected PKCS#12 file
+func ExportP12File(opts P12ExportOptions) error {
+	cert, err := LoadCertWithChainFromFile(opts.KeyPath, opts.KeyPassphrase, opts.CertPath, opts.ChainPath)
+	if err != nil {
+		return err
+	}
+	p12, err := store.EncodeP12(cert, opts.Password, opts.Encryption)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(opts.OutPath, p12, 0600)
+}
+
+// P12ImportOptions describe a PKCS#12 file to unpack in pem files
+type P12ImportOptions struct {
+	InPath string
+	// Password protecting the PKCS#12 file
+	Password     string
+	Dest         string
+	CertFileName string
+	KeyFileName  string
+	// ChainFileName if not empty, file name of the full chain written with the cert followed by the CA chain
+	ChainFileName string
+	// KeyEncryption passphrase encryption of the private key file, written in clear if no passphrase
+	KeyEncryption keys.Encryption
+}
+
+// ImportP12File unpack the cert, private key and CA chain of a PKCS#12 file in pem files
+func ImportP12File(opts P12ImportOptions) (*types.Cert, error) {
+	data, err := ioutil.ReadFile(opts.InPath)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := store.DecodeP12(data, opts.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encrypt the private key
+	keyPem, err := opts.KeyEncryption.EncodePEM(cert.Key)
+	if err != nil {
+		return nil, err
+	}
+	written := *cert
+	written.KeyPem = keyPem
+
+	_, err = writeCertFiles(&written, opts.Dest, opts.CertFileName, opts.KeyFileName, opts.ChainFileName)
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ReadPassphrase get a passphrase from a source, using the same syntax as openssl -passin:
+//
+//	pass:<passphrase>  the passphrase itself
+//	env:<name>         the value of the environment variable
+//	file:<path>        the first line of the file
+//	fd:<number>        the first line read from the file descriptor
+//	prompt             read from the terminal, twice if confirm is true
+//
+// An empty source gives an empty passphrase.
+func ReadPassphrase(source string, prompt string, confirm bool) (string, error) {
+	kind, value := source, ""
+	if i := strings.Index(source, ":"); i >= 0 {
+		kind, value = source[:i], source[i+1:]
+	}
+
+	switch kind {
+	case "":
+		return "", nil
+	case "pass":
+		return value, nil
+	case "env":
+		pass, ok := os.LookupEnv(value)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", value)
+		}
+		return pass, nil
+	case "file":
+		f, err := os.Open(value)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return readFirstLine(f)
+	case "fd":
+		fd, err := strconv.Atoi(value)
+		if err != nil || fd < 0 {
+			return "", fmt.Errorf("invalid file descriptor %q", value)
+		}
+		f := os.NewFile(uintptr(fd), "fd"+value)
+		if f == nil {
+			return "", fmt.Errorf("invalid file descriptor %q", value)
+		}
+		return readFirstLine(f)
+	case "prompt":
+		return promptPassphrase(prompt, confirm)
+	}
+	return "", fmt.Errorf("invalid passphrase source %q, expected pass:, env:, file:, fd: or prompt", source)
+}
+
+// readFirstLine read the first line of r without the line ending
+func readFirstLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// promptPassphrase read a passphrase from the terminal without echo
+func promptPassphrase(prompt string, confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("cannot prompt for a passphrase, stdin is not a terminal")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	pass, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		fmt.Fprintf(os.Stderr, "Verifying - %s: ", prompt)
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(pass) != string(again) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return string(pass), nil
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// DefaultReloadInterval polling interval of the watched files when none is set
+const DefaultReloadInterval = 10 * time.Second
+
+// ReloadOptions describe the files of a reloading server TLS configuration
+type ReloadOptions struct {
+	CertPath string
+	KeyPath  string
+	// KeyPassphrase passphrase of the private key when it's encrypted
+	KeyPassphrase string
+	// CAPaths CA certificates used to validate the client certificates, mTLS is enabled when not empty
+	CAPaths []string
+	// Interval polling interval of the files, DefaultReloadInterval if zero
+	Interval time.Duration
+	// NextProtos ALPN protocols of the server, like h2 for gRPC.
+	// The configs served by GetConfigForClient don't inherit the protocols added to a clone of the config.
+	NextProtos []string
+	// OnReload optional function called after each reload attempt, with a nil error when the new files are served
+	OnReload func(err error)
+}
+
+// ReloadingServerTLS server TLS configuration serving the latest valid cert, key and client CA pool found in its files.
+// New files are validated before being served, the last good files are kept when they are invalid.
+// It is safe for concurrent use by multiple goroutines.
+type ReloadingServerTLS struct {
+	opts ReloadOptions
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	config  *tls.Config
+	files   map[string]fileState
+	lastErr error
+}
+
+// fileState state of a watched file, used to detect changes
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloadingServerTLS load the cert, key and client CA files of the server TLS configuration.
+// The files must be valid at creation, call Watch to reload them when they change.
+func NewReloadingServerTLS(opts ReloadOptions) (*ReloadingServerTLS, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultReloadInterval
+	}
+	r := &ReloadingServerTLS{opts: opts}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// BuildReloadingServerTlsConf create a tlsConfig like BuildServerTlsConf, serving the cert, key and client CAs reloaded
+// by the returned ReloadingServerTLS
+func BuildReloadingServerTlsConf(opts ReloadOptions) (*tls.Config, *ReloadingServerTLS, error) {
+	r, err := NewReloadingServerTLS(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.TLSConfig(), r, nil
+}
+
+// TLSConfig get a tls.Config serving the latest loaded files with GetCertificate and GetConfigForClient
+func (r *ReloadingServerTLS) TLSConfig() *tls.Config {
+	return &tls.Config{
+		NextProtos: r.opts.NextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.config, nil
+		},
+	}
+}
+
+// Credentials get the gRPC server transport credentials serving the latest loaded files
+func (r *ReloadingServerTLS) Credentials() credentials.TransportCredentials {
+	tlsConfig := r.TLSConfig()
+	if len(tlsConfig.NextProtos) == 0 {
+		tlsConfig.NextProtos = []string{"h2"}
+	}
+	return credentials.NewTLS(tlsConfig)
+}
+
+// Certificate get the certificate currently served
+func (r *ReloadingServerTLS) Certificate() *x509.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert.Leaf
+}
+
+// Err get the error of the last reload attempt, nil if the latest files are served
+func (r *ReloadingServerTLS) Err() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastErr
+}
+
+// Watch poll the files until the context is done, and reload them when they change.
+// A failed reload is retried at each poll, the last good files being served meanwhile.
+// OnReload is only called again for a failure when the error changes.
+func (r *ReloadingServerTLS) Watch(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			lastErr := r.Err()
+			err := r.reload()
+			if r.opts.OnReload != nil && (err == nil || lastErr == nil || err.Error() != lastErr.Error()) {
+				r.opts.OnReload(err)
+			}
+		}
+	}
+}
+
+// Reload load and validate the files, and serve them if they are valid.
+// The error is kept for Err and reported to OnReload.
+func (r *ReloadingServerTLS) Reload() error {
+	err := r.reload()
+	if r.opts.OnReload != nil {
+		r.opts.OnReload(err)
+	}
+	return err
+}
+
+// reload load and validate the files, and serve them if they are valid
+func (r *ReloadingServerTLS) reload() error {
+	files := r.stat()
+	cert, config, err := r.load()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		r.cert = cert
+		r.config = config
+	}
+	r.files = files
+	r.lastErr = err
+	return err
+}
+
+// changed check if one of the files changed since the last reload, or if the last reload failed
+func (r *ReloadingServerTLS) changed() bool {
+	files := r.stat()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.lastErr != nil || len(files) != len(r.files) {
+		return true
+	}
+	for path, state := range files {
+		if r.files[path] != state {
+			return true
+		}
+	}
+	return false
+}
+
+// stat get the state of the files, the missing files are left out
+func (r *ReloadingServerTLS) stat() map[string]fileState {
+	files := map[string]fileState{}
+	for _, path := range append([]string{r.opts.CertPath, r.opts.KeyPath}, r.opts.CAPaths...) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return files
+}
+
+// load load and validate the key pair and the client CA pool, and build the config served to the clients
+func (r *ReloadingServerTLS) load() (*tls.Certificate, *tls.Config, error) {
+
+	// Load the key pair, the key must match the cert
+	certObj, err := LoadCertFromFile(r.opts.KeyPath, r.opts.KeyPassphrase, r.opts.CertPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	leaf := certObj.Cert
+	now := time.Now()
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return nil, nil, fmt.Errorf("%s: certificate is not valid from %s to %s", r.opts.CertPath,
+			leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+	}
+	if !serverAuthAllowed(leaf) {
+		return nil, nil, fmt.Errorf("%s: certificate extended key usages don't allow TLS server authentication", r.opts.CertPath)
+	}
+
+	tlsCert := tlsCertificate(certObj)
+	cert := &tlsCert
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		NextProtos:   r.opts.NextProtos,
+	}
+
+	// mTLS configuration
+	if len(r.opts.CAPaths) > 0 {
+		caCertPool := x509.NewCertPool()
+		for _, caPath := range r.opts.CAPaths {
+			caCert, err := ioutil.ReadFile(caPath)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !caCertPool.AppendCertsFromPEM(caCert) {
+				return nil, nil, fmt.Errorf("%s: no CA certificate found in pem file", caPath)
+			}
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = caCertPool
+	}
+
+	return cert, config, nil
+}
+
+// serverAuthAllowed check the certificate can be used by a TLS server, a certificate without extended key usage can
+func serverAuthAllowed(cert *x509.Certificate) bool {
+	if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return true
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestReloadKeepsLastGoodPair(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "srv.pem")
+	keyPath := filepath.Join(dir, "srv.key")
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(cn string, p profile.Profile, start time.Time) *types.Cert {
+		leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+			Subject:  pkix.Name{CommonName: cn},
+			SansDns:  []string{"localhost"},
+			Start:    start,
+			Duration: 30 * time.Minute,
+			Key:      types.KeyOptions{Algorithm: types.ECDSA},
+			Profile:  p,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := ca.SignCert(root, leafCSR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf
+	}
+	write := func(path string, data []byte) {
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().Add(-time.Minute)
+	first := issue("first", profile.Server, now)
+	second := issue("second", profile.Server, now)
+	clientOnly := issue("client", profile.Client, now)
+	expired := issue("expired", profile.Server, now.Add(-45*time.Minute))
+
+	write(certPath, first.CertPem.Bytes())
+	write(keyPath, first.KeyPem.Bytes())
+	var reloads []error
+	r, err := NewReloadingServerTLS(ReloadOptions{
+		CertPath: certPath,
+		KeyPath:  keyPath,
+		OnReload: func(err error) { reloads = append(reloads, err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := func() string {
+		cert, err := r.TLSConfig().GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Leaf.Subject.CommonName
+	}
+
+	tests := []struct {
+		name     string
+		certPem  []byte
+		keyPem   []byte
+		wantErr  error
+		wantCN   string
+		wantFail bool
+	}{
+		{
+			name:     "new cert written before its key",
+			certPem:  second.CertPem.Bytes(),
+			keyPem:   first.KeyPem.Bytes(),
+			wantErr:  keys.ErrKeyMismatch,
+			wantCN:   "first",
+			wantFail: true,
+		},
+		{
+			name:     "cert without server usage",
+			certPem:  clientOnly.CertPem.Bytes(),
+			keyPem:   clientOnly.KeyPem.Bytes(),
+			wantCN:   "first",
+			wantFail: true,
+		},
+		{
+			name:     "expired cert",
+			certPem:  expired.CertPem.Bytes(),
+			keyPem:   expired.KeyPem.Bytes(),
+			wantCN:   "first",
+			wantFail: true,
+		},
+		{
+			name:     "truncated key",
+			certPem:  second.CertPem.Bytes(),
+			keyPem:   second.KeyPem.Bytes()[:20],
+			wantCN:   "first",
+			wantFail: true,
+		},
+		{
+			name:    "valid pair picked up",
+			certPem: second.CertPem.Bytes(),
+			keyPem:  second.KeyPem.Bytes(),
+			wantCN:  "second",
+		},
+		{
+			name:     "mismatch after the new pair keeps the new pair",
+			certPem:  first.CertPem.Bytes(),
+			keyPem:   second.KeyPem.Bytes(),
+			wantErr:  keys.ErrKeyMismatch,
+			wantCN:   "second",
+			wantFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write(certPath, tt.certPem)
+			write(keyPath, tt.keyPem)
+			reloads = nil
+
+			err := r.Reload()
+			if tt.wantFail != (err != nil) {
+				t.Fatalf("Reload() error = %v, want failure %v", err, tt.wantFail)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Reload() error = %v, want %v", err, tt.wantErr)
+			}
+			if r.Err() != err {
+				t.Errorf("Err() = %v, want the Reload error %v", r.Err(), err)
+			}
+			if len(reloads) != 1 || reloads[0] != err {
+				t.Errorf("OnReload calls %v, want [%v]", reloads, err)
+			}
+			if cn := r.Certificate().Subject.CommonName; cn != tt.wantCN {
+				t.Errorf("Certificate() CN = %s, want %s", cn, tt.wantCN)
+			}
+			if cn := served(); cn != tt.wantCN {
+				t.Errorf("served certificate CN = %s, want %s", cn, tt.wantCN)
+			}
+		})
+	}
+}
+
+func TestWatchPicksUpValidPair(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "srv.pem")
+	keyPath := filepath.Join(dir, "srv.key")
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leaves []*types.Cert
+	for _, cn := range []string{"first", "second"} {
+		leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+			Subject:  pkix.Name{CommonName: cn},
+			Start:    time.Now().Add(-time.Minute),
+			Duration: time.Hour,
+			Key:      types.KeyOptions{Algorithm: types.ECDSA},
+			Profile:  profile.Server,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := ca.SignCert(root, leafCSR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaves = append(leaves, leaf)
+	}
+	if err := ioutil.WriteFile(certPath, leaves[0].CertPem.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, leaves[0].KeyPem.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The files are replaced with a rename, a poll never reads a partly written file.
+	// Each write gets a later modification time, the test writes are closer than the file system time granularity.
+	modTime := time.Now()
+	replace := func(path string, data []byte) {
+		if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		modTime = modTime.Add(time.Second)
+		if err := os.Chtimes(path+".tmp", modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := make(chan error, 10)
+	r, err := NewReloadingServerTLS(ReloadOptions{
+		CertPath: certPath,
+		KeyPath:  keyPath,
+		Interval: 10 * time.Millisecond,
+		OnReload: func(err error) { results <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-results; err != nil {
+		t.Fatalf("initial load error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx)
+
+	next := func() error {
+		select {
+		case err := <-results:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reload after the files changed")
+			return nil
+		}
+	}
+
+	// The cert alone doesn't match the served key, the first pair is kept
+	replace(certPath, leaves[1].CertPem.Bytes())
+	if err := next(); !errors.Is(err, keys.ErrKeyMismatch) {
+		t.Fatalf("reload error = %v, want %v", err, keys.ErrKeyMismatch)
+	}
+	if cn := r.Certificate().Subject.CommonName; cn != "first" {
+		t.Errorf("Certificate() CN = %s after a mismatched write, want first", cn)
+	}
+
+	// The key completes the pair, the second cert is served
+	replace(keyPath, leaves[1].KeyPem.Bytes())
+	if err := next(); err != nil {
+		t.Fatalf("reload error = %v once the pair is complete", err)
+	}
+	if cn := r.Certificate().Subject.CommonName; cn != "second" {
+		t.Errorf("Certificate() CN = %s, want second", cn)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err() = %v after a successful reload", err)
+	}
+}
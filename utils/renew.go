@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/inventory"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/types"
+)
+
+// RenewOptions describe a certificate stored on disk to renew with a CA stored on disk
+type RenewOptions struct {
+	CAKeyPath  string
+	CACertPath string
+	// CAKeyPassphrase passphrase of the CA private key when it's encrypted
+	CAKeyPassphrase string
+	// CertPath and KeyPath of the certificate to renew, the files are replaced in place
+	CertPath string
+	KeyPath  string
+	// KeyPassphrase passphrase of the reused private key when it's encrypted
+	KeyPassphrase string
+	// ChainPath if not empty, full chain file replaced in place with the new cert followed by the CA chain.
+	// The chain file recorded in the inventory with the old certificate is used if empty.
+	ChainPath string
+	// Duration of the new certificate, the validity period of the old certificate if zero
+	Duration time.Duration
+	// RotateKey generate a new private key instead of reusing the old one
+	RotateKey bool
+	// Key algorithm and size of the rotated key, the old key algorithm and size if empty
+	Key types.KeyOptions
+	// SerialRegistry path of the CA serial registry, serial.RegistryPath(CACertPath) if empty
+	SerialRegistry string
+	// CAChainPath optional pem file with the issuer chain of the CA when the CA is an intermediate
+	CAChainPath string
+	// Inventory directory of the CA inventory, inventory.Path(CACertPath) if empty
+	Inventory string
+	// Requester recorded in the inventory, the current user if empty
+	Requester string
+	// KeyEncryption passphrase encryption of the rotated private key file, written in clear if no passphrase
+	KeyEncryption keys.Encryption
+	// Profile applied on the new certificate, replacing the usages of the old certificate.
+	// If empty, the profile recorded in the inventory is applied with the usages of the old certificate.
+	Profile profile.Profile
+	// SKIMethod method used to compute the subject key identifier,
+	// the method of the old certificate or keys.DefaultSKIMethod if empty
+	SKIMethod keys.SKIMethod
+}
+
+// RenewCertFile re-issue the certificate at opts.CertPath with the same subject, SANs, key usages and profile.
+// The previous cert, key and chain files are kept with the .bak extension and the new files are written atomically in place.
+func RenewCertFile(opts RenewOptions) error {
+
+	// Load CA
+	caCert, err := LoadCAFromFile(opts.CAKeyPath, opts.CAKeyPassphrase, opts.CACertPath, opts.CAChainPath)
+	if err != nil {
+		return err
+	}
+
+	// Load the certificate to renew, the key is only required when it's reused
+	old := &types.Cert{}
+	if opts.RotateKey {
+		certs, err := LoadChainFromFile(opts.CertPath)
+		if err != nil {
+			return err
+		}
+		old.Cert = certs[0]
+	} else {
+		old, err = LoadCertFromFile(opts.KeyPath, opts.KeyPassphrase, opts.CertPath)
+		if err != nil {
+			return err
+		}
+	}
+	if err := old.Cert.CheckSignatureFrom(caCert.Cert); err != nil {
+		return fmt.Errorf("%s is not issued by %s: %v", opts.CertPath, opts.CACertPath, err)
+	}
+
+	// Get the profile of the old certificate from the CA inventory
+	inventoryPath := opts.Inventory
+	if inventoryPath == "" {
+		inventoryPath = inventory.Path(opts.CACertPath)
+	}
+	inv, err := inventory.Open(inventoryPath)
+	if err != nil {
+		return err
+	}
+	p := opts.Profile
+	record, err := inv.Get(old.Cert.SerialNumber)
+	switch {
+	case err == nil:
+		// The recorded profile keeps the usages the old certificate was issued with, which may be overridden
+		if found, err := profile.Lookup(record.Profile); err == nil && p.Name == "" {
+			p = found
+			p.KeyUsage = old.Cert.KeyUsage
+			p.ExtKeyUsage = old.Cert.ExtKeyUsage
+			p.UnknownExtKeyUsage = old.Cert.UnknownExtKeyUsage
+		}
+	case errors.Is(err, inventory.ErrNotFound):
+		record = inventory.Record{}
+	default:
+		return err
+	}
+
+	// Also renew the chain file recorded with the old certificate
+	if opts.ChainPath == "" && record.CertPath == absPath(opts.CertPath) {
+		opts.ChainPath = record.ChainPath
+	}
+
+	// Get a serial number never issued by this CA
+	sn, err := nextSerial(opts.SerialRegistry, opts.CACertPath)
+	if err != nil {
+		return err
+	}
+
+	cert, err := ca.Renew(caCert, old, ca.RenewOptions{
+		Start:        time.Now(),
+		Duration:     opts.Duration,
+		SerialNumber: sn,
+		RotateKey:    opts.RotateKey,
+		Key:          opts.Key,
+		Profile:      p,
+		SKIMethod:    opts.SKIMethod,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Encode the new files before touching the previous ones
+	files := CertFiles{CertPath: opts.CertPath, ChainPath: opts.ChainPath}
+	newFiles := []fileContent{{files.CertPath, cert.CertPem.Bytes()}}
+	if opts.RotateKey {
+		files.KeyPath = opts.KeyPath
+		keyPem, err := opts.KeyEncryption.EncodePEM(cert.Key)
+		if err != nil {
+			return err
+		}
+		newFiles = append(newFiles, fileContent{files.KeyPath, keyPem.Bytes()})
+	}
+	if files.ChainPath != "" {
+		chainPem := EncodeCertsPem(append([]*x509.Certificate{cert.Cert}, cert.Chain...))
+		newFiles = append(newFiles, fileContent{files.ChainPath, chainPem.Bytes()})
+	}
+
+	// Backup the previous files then replace them, the previous files are restored if one can't be written
+	if err := replaceFiles(newFiles); err != nil {
+		return err
+	}
+
+	// Record the new cert and mark the old one superseded
+	files.KeyPath = opts.KeyPath
+	profileName := record.Profile
+	if opts.Profile.Name != "" {
+		profileName = opts.Profile.Name
+	}
+	err = RecordIssuance(inventoryPath, opts.CACertPath, cert.Cert, profileName, opts.Requester, files)
+	if err != nil {
+		return err
+	}
+	err = inv.SetStatus(old.Cert.SerialNumber, inventory.StatusSuperseded)
+	if errors.Is(err, inventory.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// fileContent new content of a file
+type fileContent struct {
+	path string
+	data []byte
+}
+
+// replaceFiles keep the previous files with the .bak extension and write the new files atomically.
+// If a file can't be written, the files already replaced are restored from their backup,
+// or removed if they didn't exist, so a new cert is never left next to an old key.
+func replaceFiles(files []fileContent) error {
+	existed := make([]bool, len(files))
+	for i, f := range files {
+		var err error
+		existed[i], err = backupFile(f.path)
+		if err != nil {
+			return err
+		}
+	}
+	for i, f := range files {
+		if err := writeFileAtomic(f.path, f.data, 0600); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if restoreErr := restoreFile(files[j].path, existed[j]); restoreErr != nil {
+					return fmt.Errorf("%v, and %s can't be restored: %v", err, files[j].path, restoreErr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// backupFile copy the file to path.bak, and report if the file existed
+func backupFile(path string) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, writeFileAtomic(path+".bak", data, 0600)
+}
+
+// restoreFile put back the backup of the file at path, or remove the file if it didn't exist before
+func restoreFile(path string, existed bool) error {
+	if !existed {
+		return os.Remove(path)
+	}
+	data, err := ioutil.ReadFile(path + ".bak")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// writeFileAtomic write the data in a temporary file next to path then rename it to path,
+// readers never see a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/keys"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestRenewCertFileKeepsPairOnKeyEncodingError(t *testing.T) {
+	dir := t.TempDir()
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+		Subject:  pkix.Name{CommonName: "app"},
+		Start:    time.Now(),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ca.SignCert(root, leafCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"ca.pem":  root.CertPem.Bytes(),
+		"ca.key":  root.KeyPem.Bytes(),
+		"app.pem": leaf.CertPem.Bytes(),
+		"app.key": leaf.KeyPem.Bytes(),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = RenewCertFile(RenewOptions{
+		CAKeyPath:     filepath.Join(dir, "ca.key"),
+		CACertPath:    filepath.Join(dir, "ca.pem"),
+		CertPath:      filepath.Join(dir, "app.pem"),
+		KeyPath:       filepath.Join(dir, "app.key"),
+		RotateKey:     true,
+		KeyEncryption: keys.Encryption{Passphrase: []byte("secret"), KDF: "unsupported"},
+	})
+	if err == nil {
+		t.Fatal("renew with an unsupported key derivation function succeeded")
+	}
+	for _, name := range []string{"app.pem", "app.key"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, files[name]) {
+			t.Errorf("%s was replaced by the failed renewal", name)
+		}
+	}
+}
+
+func TestReplaceFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing files before the replacement
+		existing map[string]string
+		files    []fileContent
+		wantErr  bool
+		// want files after the replacement, a missing file is an empty string
+		want map[string]string
+	}{
+		{
+			name:     "replace",
+			existing: map[string]string{"cert": "old cert", "key": "old key"},
+			files:    []fileContent{{"cert", []byte("new cert")}, {"key", []byte("new key")}},
+			want:     map[string]string{"cert": "new cert", "key": "new key", "cert.bak": "old cert", "key.bak": "old key"},
+		},
+		{
+			name:     "create",
+			existing: map[string]string{"cert": "old cert"},
+			files:    []fileContent{{"cert", []byte("new cert")}, {"chain", []byte("new chain")}},
+			want:     map[string]string{"cert": "new cert", "chain": "new chain", "cert.bak": "old cert", "chain.bak": ""},
+		},
+		{
+			name:     "restore on write error",
+			existing: map[string]string{"cert": "old cert", "key": "old key"},
+			files:    []fileContent{{"cert", []byte("new cert")}, {"key", []byte("new key")}, {"missing/chain", []byte("new chain")}},
+			wantErr:  true,
+			want:     map[string]string{"cert": "old cert", "key": "old key"},
+		},
+		{
+			name:     "remove created files on write error",
+			existing: map[string]string{"cert": "old cert"},
+			files:    []fileContent{{"cert", []byte("new cert")}, {"key", []byte("new key")}, {"missing/chain", []byte("new chain")}},
+			wantErr:  true,
+			want:     map[string]string{"cert": "old cert", "key": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.existing {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var files []fileContent
+			for _, f := range tt.files {
+				files = append(files, fileContent{filepath.Join(dir, f.path), f.data})
+			}
+
+			if err := replaceFiles(files); (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				data, err := ioutil.ReadFile(filepath.Join(dir, name))
+				if want == "" {
+					if !os.IsNotExist(err) {
+						t.Errorf("%s exists, want no file", name)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != want {
+					t.Errorf("%s content %q, want %q", name, data, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/sundae-party/pki/spiffe"
+)
+
+// VerifySVID check the peer certificate is a leaf X.509-SVID of the trust domain and get its SPIFFE ID.
+// The chain of the certificate must be verified separately, see SVIDVerifier.
+func VerifySVID(cert *x509.Certificate, trustDomain string) (spiffe.ID, error) {
+	id, err := spiffe.CheckLeaf(cert)
+	if err != nil {
+		return spiffe.ID{}, err
+	}
+	if trustDomain != "" && !id.MemberOf(trustDomain) {
+		return spiffe.ID{}, fmt.Errorf("%w: SPIFFE ID %s is not in trust domain %s", spiffe.ErrInvalidSVID, id, trustDomain)
+	}
+	return id, nil
+}
+
+// SVIDVerifier build a tls.Config VerifyPeerCertificate callback accepting the X.509-SVIDs of the trust domain
+// signed by the roots. If allowedIDs are given, the peer SPIFFE ID must be one of them.
+// SPIFFE peers are not identified by host name, the TLS config using it on the client side must set
+// InsecureSkipVerify to disable the default host name verification, the callback verifies the chain.
+func SVIDVerifier(roots *x509.CertPool, trustDomain string, allowedIDs ...string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no peer certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+
+		// Verify the chain up to the trust domain roots
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return err
+		}
+
+		id, err := VerifySVID(certs[0], trustDomain)
+		if err != nil {
+			return err
+		}
+		if len(allowedIDs) == 0 {
+			return nil
+		}
+		for _, allowed := range allowedIDs {
+			if id.String() == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("SPIFFE ID %s is not allowed", id)
+	}
+}
+
+// PeerSPIFFEID get the SPIFFE ID of the gRPC peer authenticated with mTLS
+func PeerSPIFFEID(ctx context.Context) (spiffe.ID, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return spiffe.ID{}, errors.New("no gRPC peer in context")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return spiffe.ID{}, errors.New("gRPC peer is not authenticated with TLS")
+	}
+	if len(tlsInfo.State.PeerCertificates) == 0 {
+		return spiffe.ID{}, errors.New("no peer certificate")
+	}
+	return spiffe.FromURIs(tlsInfo.State.PeerCertificates[0].URIs)
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/spiffe"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestSVIDVerifier(t *testing.T) {
+	roots := map[string]*types.Cert{}
+	for _, name := range []string{"trusted", "other"} {
+		root, err := ca.CreateCaWithOptions(ca.Options{
+			Subject:  pkix.Name{CommonName: name + " root"},
+			Start:    time.Now().Add(-time.Minute),
+			Duration: time.Hour,
+			Key:      types.KeyOptions{Algorithm: types.ECDSA},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots[name] = root
+	}
+	issue := func(root string, p profile.Profile, uris ...string) *x509.Certificate {
+		var sans []*url.URL
+		for _, uri := range uris {
+			u, err := url.Parse(uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sans = append(sans, u)
+		}
+		leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+			SansURI:  sans,
+			Start:    time.Now().Add(-time.Minute),
+			Duration: time.Hour,
+			Key:      types.KeyOptions{Algorithm: types.ECDSA},
+			Profile:  p,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := ca.SignCert(roots[root], leafCSR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Cert
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(roots["trusted"].Cert)
+
+	tests := []struct {
+		name        string
+		cert        *x509.Certificate
+		trustDomain string
+		allowedIDs  []string
+		wantErr     bool
+		// is optional error expected in the error chain
+		is error
+	}{
+		{name: "svid", cert: issue("trusted", profile.SVID, "spiffe://example.org/api"), trustDomain: "example.org"},
+		{name: "any trust domain", cert: issue("trusted", profile.SVID, "spiffe://example.org/api")},
+		{name: "allowed ID", cert: issue("trusted", profile.SVID, "spiffe://example.org/api"), trustDomain: "example.org", allowedIDs: []string{"spiffe://example.org/web", "spiffe://example.org/api"}},
+		{name: "not allowed ID", cert: issue("trusted", profile.SVID, "spiffe://example.org/api"), trustDomain: "example.org", allowedIDs: []string{"spiffe://example.org/web"}, wantErr: true},
+		{name: "other trust domain", cert: issue("trusted", profile.SVID, "spiffe://other.org/api"), trustDomain: "example.org", wantErr: true, is: spiffe.ErrInvalidSVID},
+		{name: "untrusted root", cert: issue("other", profile.SVID, "spiffe://example.org/api"), trustDomain: "example.org", wantErr: true},
+		{name: "no SPIFFE ID", cert: issue("trusted", profile.Peer, "https://example.org/api"), wantErr: true, is: spiffe.ErrInvalidSVID},
+		{name: "two URI SANs", cert: issue("trusted", profile.Peer, "spiffe://example.org/api", "spiffe://example.org/web"), wantErr: true, is: spiffe.ErrInvalidSVID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verify := SVIDVerifier(pool, tt.trustDomain, tt.allowedIDs...)
+			err := verify([][]byte{tt.cert.Raw}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("got error %v, want %v", err, tt.is)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sundae-party/pki/crl"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/serial"
+)
+
+// VerifyOptions describe a certificate stored on disk to verify
+type VerifyOptions struct {
+	// CertPath cert to verify, may be followed by its intermediate CAs
+	CertPath string
+	// RootPaths trusted root CA certs
+	RootPaths []string
+	// IntermediatePaths untrusted intermediate CA certs used to build the chain
+	IntermediatePaths []string
+	// DNSName if not empty, name the cert must be valid for
+	DNSName string
+	// IP if not nil, IP address the cert must be valid for
+	IP net.IP
+	// ExtKeyUsage usages the cert must be valid for, each one is required. Any usage if empty.
+	ExtKeyUsage []x509.ExtKeyUsage
+	// At verification time, now if zero
+	At time.Time
+	// CRLPaths CRLs used to check the revocation of the certs of the chain
+	CRLPaths []string
+}
+
+// RevocationStatus revocation status of a certificate of a verified chain
+type RevocationStatus string
+
+const (
+	// RevocationGood the cert is not in the CRL of its issuer
+	RevocationGood RevocationStatus = "good"
+	// RevocationUnchecked no CRL of the cert issuer was given, or the cert is a root
+	RevocationUnchecked RevocationStatus = "unchecked"
+)
+
+// VerifiedCert certificate of a verified chain
+type VerifiedCert struct {
+	Cert       *x509.Certificate
+	Revocation RevocationStatus
+}
+
+// VerifyCertFile verify the cert against the roots, and check its names, usages and revocation.
+// The verified chains are returned from the cert to the root, the first error found otherwise.
+func VerifyCertFile(opts VerifyOptions) ([][]VerifiedCert, error) {
+
+	// Load the cert and the untrusted intermediates following it
+	certs, err := LoadChainFromFile(opts.CertPath)
+	if err != nil {
+		return nil, err
+	}
+	cert := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	for _, path := range opts.IntermediatePaths {
+		certs, err := LoadChainFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range certs {
+			intermediates.AddCert(c)
+		}
+	}
+
+	// Load the trusted roots
+	if len(opts.RootPaths) == 0 {
+		return nil, errors.New("at least one trusted root CA is required")
+	}
+	roots := x509.NewCertPool()
+	for _, path := range opts.RootPaths {
+		certs, err := LoadChainFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range certs {
+			roots.AddCert(c)
+		}
+	}
+
+	// Load the CRLs
+	var crls []*x509.RevocationList
+	for _, path := range opts.CRLPaths {
+		rl, err := crl.LoadFromFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		crls = append(crls, rl)
+	}
+
+	at := opts.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	usages := opts.ExtKeyUsage
+	if len(usages) == 0 {
+		usages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+
+	// Build and verify the chains for each usage, the Go verification accepts a chain valid for any of the usages
+	var chains [][]*x509.Certificate
+	for i, usage := range usages {
+		usageChains, err := cert.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   at,
+			KeyUsages:     []x509.ExtKeyUsage{usage},
+		})
+		if err != nil && len(usages) > 1 {
+			return nil, fmt.Errorf("%s usage: %v", profile.ExtKeyUsageNames([]x509.ExtKeyUsage{usage}, nil)[0], err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			chains = usageChains
+			continue
+		}
+		chains = commonChains(chains, usageChains)
+		if len(chains) == 0 {
+			return nil, errors.New("no chain is valid for all the extended key usages")
+		}
+	}
+
+	// Check the names
+	if opts.DNSName != "" {
+		if err := cert.VerifyHostname(opts.DNSName); err != nil {
+			return nil, err
+		}
+	}
+	if opts.IP != nil {
+		if err := cert.VerifyHostname(opts.IP.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check the revocation of each chain, keeping the chains without revoked cert
+	var verified [][]VerifiedCert
+	var revocationErr error
+	for _, chain := range chains {
+		v, err := checkChainRevocation(chain, crls, at)
+		if err != nil {
+			if revocationErr == nil {
+				revocationErr = err
+			}
+			continue
+		}
+		verified = append(verified, v)
+	}
+	if len(verified) == 0 {
+		return nil, revocationErr
+	}
+	return verified, nil
+}
+
+// checkChainRevocation check each cert of the chain against the CRLs of its issuer
+func checkChainRevocation(chain []*x509.Certificate, crls []*x509.RevocationList, at time.Time) ([]VerifiedCert, error) {
+	var verified []VerifiedCert
+	for i, cert := range chain {
+		v := VerifiedCert{Cert: cert, Revocation: RevocationUnchecked}
+		if i+1 < len(chain) {
+			issuer := chain[i+1]
+			for _, rl := range crls {
+				if !bytes.Equal(rl.RawIssuer, issuer.RawSubject) {
+					continue
+				}
+				if err := crl.Check(rl, issuer, at); err != nil {
+					return nil, fmt.Errorf("invalid CRL of %s: %v", issuer.Subject, err)
+				}
+				if entry, revoked := crl.IsRevoked(rl, cert); revoked {
+					return nil, fmt.Errorf("%w: %s serial %s revoked at %s, reason %s", crl.ErrRevoked, cert.Subject, serial.Format(cert.SerialNumber),
+						entry.RevocationTime.Format(time.RFC3339), crl.Reason(entry.ReasonCode))
+				}
+				v.Revocation = RevocationGood
+			}
+		}
+		verified = append(verified, v)
+	}
+	return verified, nil
+}
+
+// commonChains get the chains of a found in b
+func commonChains(a [][]*x509.Certificate, b [][]*x509.Certificate) [][]*x509.Certificate {
+	var common [][]*x509.Certificate
+	for _, chain := range a {
+		for _, other := range b {
+			if sameChain(chain, other) {
+				common = append(common, chain)
+				break
+			}
+		}
+	}
+	return common
+}
+
+// sameChain check if the chains have the same certificates in the same order
+func sameChain(a []*x509.Certificate, b []*x509.Certificate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sundae-party/pki/ca"
+	"github.com/sundae-party/pki/csr"
+	"github.com/sundae-party/pki/profile"
+	"github.com/sundae-party/pki/types"
+)
+
+func TestVerifyCertFileUsages(t *testing.T) {
+	dir := t.TempDir()
+	root, err := ca.CreateCaWithOptions(ca.Options{
+		Subject:  pkix.Name{CommonName: "test root"},
+		Start:    time.Now().Add(-time.Minute),
+		Duration: time.Hour,
+		Key:      types.KeyOptions{Algorithm: types.ECDSA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootPath := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(rootPath, root.CertPem.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	certPaths := map[string]string{}
+	for _, p := range []profile.Profile{profile.Server, profile.Peer} {
+		leafCSR, err := csr.CreateCSRWithOptions(csr.Options{
+			Subject:  pkix.Name{CommonName: p.Name},
+			SansDns:  []string{"app.example.com"},
+			Start:    time.Now().Add(-time.Minute),
+			Duration: time.Hour,
+			Key:      types.KeyOptions{Algorithm: types.ECDSA},
+			Profile:  p,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := ca.SignCert(root, leafCSR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certPaths[p.Name] = filepath.Join(dir, p.Name+".pem")
+		if err := ioutil.WriteFile(certPaths[p.Name], leaf.CertPem.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serverAuth := x509.ExtKeyUsageServerAuth
+	clientAuth := x509.ExtKeyUsageClientAuth
+	tests := []struct {
+		name    string
+		cert    string
+		usages  []x509.ExtKeyUsage
+		dnsName string
+		wantErr bool
+	}{
+		{"server cert any usage", "server", nil, "", false},
+		{"server cert for server", "server", []x509.ExtKeyUsage{serverAuth}, "app.example.com", false},
+		{"server cert for client", "server", []x509.ExtKeyUsage{clientAuth}, "", true},
+		{"server cert for server and client", "server", []x509.ExtKeyUsage{serverAuth, clientAuth}, "", true},
+		{"server cert for client and server", "server", []x509.ExtKeyUsage{clientAuth, serverAuth}, "", true},
+		{"peer cert for server and client", "peer", []x509.ExtKeyUsage{serverAuth, clientAuth}, "", false},
+		{"other name", "peer", nil, "other.example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chains, err := VerifyCertFile(VerifyOptions{
+				CertPath:    certPaths[tt.cert],
+				RootPaths:   []string{rootPath},
+				ExtKeyUsage: tt.usages,
+				DNSName:     tt.dnsName,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (len(chains) != 1 || len(chains[0]) != 2) {
+				t.Errorf("got chains %v, want one chain of 2 certs", chains)
+			}
+		})
+	}
+}